@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// Up-to-date checks of batch mode
+const (
+	skipMtime = "mtime" // skip outputs newer than their input and the template
+	skipHash  = "hash"  // skip outputs whose input and template hash is unchanged since the last run
+	skipNone  = "none"  // convert everything
+)
+
+// hashStateFile records the input hashes of a batch for -skip hash, in the output directory
+const hashStateFile = ".md-pdf-cli-hashes.json"
+
+// Batch document statuses
+const (
+	statusConverted = "ok"
+	statusSkipped   = "up-to-date"
+	statusFailed    = "failed"
+)
+
+// batchOptions configures a batch conversion
+type batchOptions struct {
+	templateFile string
+	limits       *limitFlags
+	write        *writeFlags
+	recursive    bool
+	outDir       string
+	jobs         int
+	skip         string
+	json         bool
+}
+
+// batchDocument is one input of a batch and where its PDF goes
+type batchDocument struct {
+	input  string
+	output string
+}
+
+// batchResult is the outcome of converting one batchDocument
+type batchResult struct {
+	batchDocument
+	status   string
+	bytes    int // size of the written PDF, which is not kept in memory
+	pages    int
+	warnings []mdpdf.Diagnostic
+	duration time.Duration
+	hash     string
+	err      error
+}
+
+// runBatch converts every markdown file named by args (files, directories or
+// globs) to its own PDF and prints a summary table. It fails if any document failed.
+func runBatch(args []string, opts batchOptions) error {
+	if opts.skip != skipMtime && opts.skip != skipHash && opts.skip != skipNone {
+		return usageError("-skip must be mtime, hash or none (got %q)", opts.skip)
+	}
+	if opts.jobs < 1 {
+		return usageError("-j must be at least 1 (got %d)", opts.jobs)
+	}
+
+	documents, err := collectDocuments(args, opts.recursive, opts.outDir)
+	if err != nil {
+		return err
+	}
+	if len(documents) == 0 {
+		return usageError("no markdown files found")
+	}
+
+	converter, err := opts.limits.converter(opts.templateFile)
+	if err != nil {
+		return err
+	}
+	template, err := converter.GetTemplateContent()
+	if err != nil {
+		return err
+	}
+	// The embedded template only changes with the binary, which -skip mtime ignores
+	var templateModTime time.Time
+	if opts.templateFile != "" {
+		templateInfo, err := os.Stat(opts.templateFile)
+		if err != nil {
+			return err
+		}
+		templateModTime = templateInfo.ModTime()
+	}
+
+	stateDir := opts.outDir
+	if stateDir == "" {
+		stateDir = "."
+	}
+	statePath := filepath.Join(stateDir, hashStateFile)
+	hashes := map[string]string{}
+	if opts.skip == skipHash {
+		if data, err := os.ReadFile(statePath); err == nil {
+			if err := json.Unmarshal(data, &hashes); err != nil {
+				return fmt.Errorf("invalid hash state %s: %w", statePath, err)
+			}
+		}
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "🔄 Converting %d documents with %d workers...\n", len(documents), min(opts.jobs, len(documents)))
+
+	results := make([]batchResult, len(documents))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(opts.jobs, len(documents)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = convertDocument(ctx, converter, documents[i], opts, []byte(template), templateModTime, hashes)
+			}
+		}()
+	}
+	for i := range documents {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	reports := make([]documentReport, len(results))
+	errs := make([]error, len(results))
+	failed := 0
+	for i, res := range results {
+		reports[i] = newDocumentReport(res.input, res.output, res.status, nil, res.duration, res.err)
+		reports[i].Bytes, reports[i].Pages, reports[i].Warnings = res.bytes, res.pages, res.warnings
+		errs[i] = res.err
+		if res.err != nil {
+			failed++
+		}
+	}
+	failure := firstFailure(failed, len(results), errs)
+
+	if opts.json {
+		printJSON(reports, failure)
+	} else {
+		printBatchSummary(results)
+	}
+
+	if opts.skip == skipHash {
+		for _, res := range results {
+			if res.status == statusConverted {
+				hashes[res.output] = res.hash
+			}
+		}
+		if err := writeHashState(statePath, hashes); err != nil {
+			return fmt.Errorf("failed to write hash state: %w", err)
+		}
+	}
+
+	return failure
+}
+
+// convertDocument converts one document unless its output is up to date or the
+// batch was interrupted. hashes is only read, so workers can share it.
+func convertDocument(ctx context.Context, converter *mdpdf.Converter, doc batchDocument, opts batchOptions, template []byte, templateModTime time.Time, hashes map[string]string) batchResult {
+	res := batchResult{batchDocument: doc}
+	if err := ctx.Err(); err != nil {
+		res.status, res.err = statusFailed, opts.limits.describe(err)
+		return res
+	}
+
+	markdown, err := os.ReadFile(doc.input)
+	if err != nil {
+		res.status, res.err = statusFailed, err
+		return res
+	}
+	res.hash = documentHash(template, markdown)
+
+	if outputUpToDate(doc, opts.skip, templateModTime, res.hash, hashes) {
+		res.status = statusSkipped
+		return res
+	}
+	if err := opts.write.checkClobber(doc.output); err != nil {
+		res.status, res.err = statusFailed, err
+		return res
+	}
+
+	startTime := time.Now()
+	result, err := compileMarkdown(ctx, converter, opts.limits, filepath.Dir(doc.input), string(markdown))
+	res.duration = time.Since(startTime)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(doc.output), 0755)
+	}
+	if err == nil {
+		err = opts.write.writeOutput(doc.output, result.PDF)
+	}
+	if err != nil {
+		res.status, res.err = statusFailed, err
+		return res
+	}
+
+	res.status, res.bytes, res.pages = statusConverted, len(result.PDF), mdpdf.CountPages(result.PDF)
+	res.warnings = result.Warnings
+	return res
+}
+
+// outputUpToDate reports whether the output of doc can be kept
+func outputUpToDate(doc batchDocument, skip string, templateModTime time.Time, hash string, hashes map[string]string) bool {
+	output, err := os.Stat(doc.output)
+	if err != nil {
+		return false
+	}
+
+	switch skip {
+	case skipMtime:
+		input, err := os.Stat(doc.input)
+		return err == nil && !output.ModTime().Before(input.ModTime()) && !output.ModTime().Before(templateModTime)
+	case skipHash:
+		return hashes[doc.output] == hash
+	default:
+		return false
+	}
+}
+
+// documentHash identifies the template and markdown a PDF was built from
+func documentHash(template, markdown []byte) string {
+	h := sha256.New()
+	h.Write(template)
+	h.Write([]byte{0})
+	h.Write(markdown)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeHashState saves the hashes of converted documents for the next -skip hash run
+func writeHashState(path string, hashes map[string]string) error {
+	data, err := json.MarshalIndent(hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return mdpdf.WriteFile(path, data, 0, false)
+}
+
+// collectDocuments expands file, directory and glob arguments into documents.
+// Files found in a directory keep their path relative to it below outDir.
+func collectDocuments(args []string, recursive bool, outDir string) ([]batchDocument, error) {
+	var documents []batchDocument
+	inputs := map[string]bool{}
+	outputs := map[string]string{}
+
+	add := func(input, rel string) error {
+		input = filepath.Clean(input)
+		if inputs[input] {
+			return nil
+		}
+		inputs[input] = true
+
+		output := strings.TrimSuffix(input, filepath.Ext(input)) + ".pdf"
+		if outDir != "" {
+			output = filepath.Join(outDir, strings.TrimSuffix(rel, filepath.Ext(rel))+".pdf")
+		}
+		if other, ok := outputs[output]; ok {
+			return usageError("%s and %s would both be written to %s", other, input, output)
+		}
+		outputs[output] = input
+
+		documents = append(documents, batchDocument{input: input, output: output})
+		return nil
+	}
+
+	for _, arg := range args {
+		paths := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, usageError("invalid pattern %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, usageError("no files match %q", arg)
+			}
+			paths = matches
+		}
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				if err := add(path, filepath.Base(path)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			files, err := markdownFiles(path, recursive)
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range files {
+				rel, err := filepath.Rel(path, file)
+				if err != nil {
+					return nil, err
+				}
+				if err := add(file, rel); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return documents, nil
+}
+
+// markdownFiles returns the markdown files in dir, including subdirectories if recursive
+func markdownFiles(dir string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".md", ".markdown":
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// printBatchSummary prints one table row per document followed by the errors
+func printBatchSummary(results []batchResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tINPUT\tOUTPUT\tBYTES\tTIME")
+
+	var converted, skipped, failed int
+	for _, res := range results {
+		bytes, duration := "-", "-"
+		switch res.status {
+		case statusConverted:
+			converted++
+			bytes, duration = fmt.Sprint(res.bytes), res.duration.Round(time.Millisecond).String()
+		case statusSkipped:
+			skipped++
+		case statusFailed:
+			failed++
+			if res.duration > 0 {
+				duration = res.duration.Round(time.Millisecond).String()
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", res.status, res.input, res.output, bytes, duration)
+	}
+	w.Flush()
+
+	for _, res := range results {
+		if res.err != nil {
+			fmt.Printf("\n❌ %s: %v\n", res.input, res.err)
+		}
+	}
+
+	fmt.Printf("\n📄 %d documents: %d converted, %d up to date, %d failed\n", len(results), converted, skipped, failed)
+}
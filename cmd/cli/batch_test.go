@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("# "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollectDocuments(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "exams/a.md", "exams/notes.txt", "exams/week1/b.md", "exams/week1/deep/c.markdown", "extra/d.md")
+
+	outDir := filepath.Join(dir, "out")
+	documents, err := collectDocuments([]string{filepath.Join(dir, "exams"), filepath.Join(dir, "extra", "*.md")}, true, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		filepath.Join(dir, "exams/a.md"):                  filepath.Join(outDir, "a.pdf"),
+		filepath.Join(dir, "exams/week1/b.md"):            filepath.Join(outDir, "week1/b.pdf"),
+		filepath.Join(dir, "exams/week1/deep/c.markdown"): filepath.Join(outDir, "week1/deep/c.pdf"),
+		filepath.Join(dir, "extra/d.md"):                  filepath.Join(outDir, "d.pdf"),
+	}
+	if len(documents) != len(expected) {
+		t.Fatalf("Expected %d documents, got %+v", len(expected), documents)
+	}
+	for _, doc := range documents {
+		if expected[doc.input] != doc.output {
+			t.Errorf("%s: expected output %s, got %s", doc.input, expected[doc.input], doc.output)
+		}
+	}
+
+	// Without -r only the top level of a directory is converted
+	documents, err = collectDocuments([]string{filepath.Join(dir, "exams")}, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documents) != 1 || documents[0].output != filepath.Join(dir, "exams/a.pdf") {
+		t.Fatalf("Expected only a.md next to its input, got %+v", documents)
+	}
+
+	// Two inputs writing the same output are rejected
+	writeFiles(t, dir, "other/a.md")
+	if _, err := collectDocuments([]string{filepath.Join(dir, "exams/a.md"), filepath.Join(dir, "other/a.md")}, false, outDir); err == nil {
+		t.Fatal("Expected an output collision error")
+	}
+}
+
+func TestOutputUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.md", "a.pdf")
+	doc := batchDocument{input: filepath.Join(dir, "a.md"), output: filepath.Join(dir, "a.pdf")}
+
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(doc.input, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if !outputUpToDate(doc, skipMtime, past, "", nil) {
+		t.Error("Expected output newer than input and template to be up to date")
+	}
+	if outputUpToDate(doc, skipMtime, time.Now().Add(time.Hour), "", nil) {
+		t.Error("Expected output older than the template to be stale")
+	}
+	if !outputUpToDate(doc, skipHash, past, "abc", map[string]string{doc.output: "abc"}) {
+		t.Error("Expected output with unchanged hash to be up to date")
+	}
+	if outputUpToDate(doc, skipHash, past, "def", map[string]string{doc.output: "abc"}) {
+		t.Error("Expected output with changed hash to be stale")
+	}
+	if outputUpToDate(doc, skipNone, past, "", nil) {
+		t.Error("Expected -skip none to convert everything")
+	}
+}
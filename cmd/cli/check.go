@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// runValidate checks that templates contain the placeholder and compile
+func runValidate(args []string) error {
+	fs := newFlagSet("validate", "validate [-template <file>] [template-file...]")
+	templateFile := fs.String("template", "", "Template file path, used when no files are given (default: the built-in template)")
+	jsonOutput := fs.Bool("json", false, "Print a JSON report")
+	limits := addLimitFlags(fs)
+	fs.Parse(args)
+
+	templates := fs.Args()
+	if len(templates) == 0 {
+		templates = []string{*templateFile}
+		if *templateFile == "" {
+			templates[0] = builtinTemplate
+		}
+	}
+
+	// Check the flags before the first template
+	if _, err := limits.options(""); err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	return runChecks(templates, *jsonOutput, func(template string) (*mdpdf.ConvertResult, error) {
+		if template == builtinTemplate {
+			template = ""
+		}
+		converter, err := limits.converter(template)
+		if err != nil {
+			return nil, err
+		}
+		return nil, limits.describe(converter.ValidateTemplateContext(ctx))
+	})
+}
+
+// runLint checks that markdown files convert with a template, without writing PDFs
+func runLint(args []string) error {
+	fs := newFlagSet("lint", "lint [-template <file>] <markdown-file>...")
+	templateFile := fs.String("template", "", "Template file path (default: the built-in template)")
+	jsonOutput := fs.Bool("json", false, "Print a JSON report")
+	limits := addLimitFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return usageError("no markdown files given")
+	}
+
+	converter, err := limits.converter(*templateFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	return runChecks(fs.Args(), *jsonOutput, func(input string) (*mdpdf.ConvertResult, error) {
+		result, err := converter.ConvertFromFileResult(ctx, input)
+		return result, limits.describe(err)
+	})
+}
+
+// runChecks runs check for every file, prints one line per file or a JSON
+// report, and fails with the exit code of the first failed file
+func runChecks(files []string, jsonOutput bool, check func(name string) (*mdpdf.ConvertResult, error)) error {
+	reports := make([]documentReport, len(files))
+	errs := make([]error, len(files))
+	failed := 0
+	for i, name := range files {
+		startTime := time.Now()
+		result, err := check(name)
+		status := statusConverted
+		if err != nil {
+			status = statusFailed
+			failed++
+		}
+		reports[i] = newDocumentReport(name, "", status, result, time.Since(startTime), err)
+		errs[i] = err
+
+		if !jsonOutput {
+			reportCheck(name, err)
+		}
+	}
+
+	failure := firstFailure(failed, len(files), errs)
+	if jsonOutput {
+		printJSON(reports, failure)
+	}
+	return failure
+}
+
+// reportCheck prints the result of checking one file
+func reportCheck(name string, err error) {
+	if err != nil {
+		fmt.Printf("❌ %s: %v\n", name, err)
+		return
+	}
+	fmt.Printf("✅ %s\n", name)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// runConvert converts one markdown document, several merged into one, or a batch
+// of separate documents given as file arguments
+func runConvert(args []string) error {
+	fs := newFlagSet("convert", "convert -input <markdown-file> [options]\n  md-pdf-cli convert [options] <file|directory|glob>...")
+
+	var inputFiles stringList
+	fs.Var(&inputFiles, "input", "Input markdown file, \"-\" for standard input (required, repeat to merge several files)")
+
+	var (
+		outputFile   = fs.String("output", "", "Output PDF file, \"-\" for standard output (optional, defaults to input.pdf, or standard output for standard input)")
+		templateFile = fs.String("template", "", "Template file path (default: the built-in template)")
+		pageBreaks   = fs.Bool("page-breaks", true, "Start each merged input on a new page")
+		partHeadings = fs.Bool("part-headings", false, "Insert a heading named after each merged input file")
+		toc          = fs.Bool("toc", false, "Generate a table of contents")
+		tocTitle     = fs.String("toc-title", "", "Table of contents title (default: Contents)")
+		recursive    = fs.Bool("r", false, "Batch mode: include markdown files in subdirectories of directory arguments")
+		outDir       = fs.String("outdir", "", "Batch mode: output directory, mirroring the input directory structure (default: next to each input)")
+		jobs         = fs.Int("j", runtime.NumCPU(), "Batch mode: number of documents compiled in parallel")
+		skip         = fs.String("skip", skipMtime, "Batch mode: skip up-to-date outputs by \"mtime\" or \"hash\", or \"none\" to convert everything")
+		jsonOutput   = fs.Bool("json", false, "Print a JSON report instead of the summary")
+	)
+	limits := addLimitFlags(fs)
+	write := addWriteFlags(fs, true)
+
+	fs.Parse(args)
+
+	// File arguments convert every document separately
+	if fs.NArg() > 0 {
+		if len(inputFiles) > 0 || *outputFile != "" {
+			return usageError("-input and -output cannot be combined with file arguments")
+		}
+		return runBatch(fs.Args(), batchOptions{
+			templateFile: *templateFile,
+			limits:       limits,
+			write:        write,
+			recursive:    *recursive,
+			outDir:       *outDir,
+			jobs:         *jobs,
+			skip:         *skip,
+			json:         *jsonOutput,
+		})
+	}
+
+	if len(inputFiles) == 0 {
+		fs.Usage()
+		return usageError("missing -input")
+	}
+
+	// Determine output file
+	output := *outputFile
+	if output == "" {
+		ext := filepath.Ext(inputFiles[0])
+		output = strings.TrimSuffix(inputFiles[0], ext) + ".pdf"
+		if inputFiles[0] == stdioPath {
+			output = stdioPath
+		}
+	}
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+	if err := write.checkClobber(output); err != nil {
+		return err
+	}
+	if *jsonOutput && output == stdioPath {
+		return usageError("-json cannot be combined with writing the PDF to standard output")
+	}
+
+	converter, err := limits.converter(*templateFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// report prints the -json report of the single document
+	report := func(result *mdpdf.ConvertResult, duration time.Duration, err error) {
+		if !*jsonOutput {
+			return
+		}
+		status := statusConverted
+		if err != nil {
+			status = statusFailed
+		}
+		printJSON([]documentReport{newDocumentReport(strings.Join(inputFiles, ", "), output, status, result, duration, err)}, err)
+	}
+
+	// Read and merge inputs
+	parts, err := readParts(inputFiles)
+	if err != nil {
+		report(nil, 0, err)
+		return err
+	}
+
+	markdownContent := parts[0].Markdown
+	if len(parts) > 1 || *toc {
+		markdownContent = mdpdf.MergeMarkdown(parts, mdpdf.MergeOptions{
+			PageBreaks:      *pageBreaks,
+			PartHeadings:    *partHeadings,
+			TableOfContents: *toc,
+			TOCTitle:        *tocTitle,
+		})
+	}
+
+	// Convert; relative paths resolve against the directory of the first input
+	inputNames := make([]string, len(inputFiles))
+	for i, input := range inputFiles {
+		inputNames[i] = inputName(input)
+	}
+	result, duration, err := convertMarkdownToPDF(ctx, converter, limits, write, strings.Join(inputNames, ", "), filepath.Dir(inputFiles[0]), markdownContent, output)
+	report(result, duration, err)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "✅ PDF generated successfully: %s\n", outputName(output))
+	return nil
+}
+
+// convertMarkdownToPDF converts markdown and writes the PDF, returning the
+// conversion result and the compile time
+func convertMarkdownToPDF(ctx context.Context, converter *mdpdf.Converter, limits *limitFlags, write *writeFlags, inputName, baseDir, markdownContent, outputFile string) (*mdpdf.ConvertResult, time.Duration, error) {
+	fmt.Fprintf(os.Stderr, "🔄 Converting %s to PDF...\n", inputName)
+	startTime := time.Now()
+
+	result, err := compileMarkdown(ctx, converter, limits, baseDir, markdownContent)
+	duration := time.Since(startTime)
+	if err != nil {
+		return nil, duration, err
+	}
+
+	// Write PDF file
+	if err := write.writeOutput(outputFile, result.PDF); err != nil {
+		return nil, duration, fmt.Errorf("failed to write PDF file: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "📄 Generated %d bytes in %v\n", len(result.PDF), duration)
+	return result, duration, nil
+}
+
+// compileMarkdown converts markdown whose relative paths resolve against baseDir;
+// the converter applies -timeout per document
+func compileMarkdown(ctx context.Context, converter *mdpdf.Converter, limits *limitFlags, baseDir, markdownContent string) (*mdpdf.ConvertResult, error) {
+	result, err := converter.ConvertFromStringResult(ctx, markdownContent, mdpdf.WithBaseDir(baseDir))
+	return result, limits.describe(err)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// limitFlags are the conversion limits of the converting commands
+type limitFlags struct {
+	timeout time.Duration
+	maxSize int64
+}
+
+// addLimitFlags registers -timeout and -max-size with the library defaults
+func addLimitFlags(fs *flag.FlagSet) *limitFlags {
+	defaults := mdpdf.DefaultOptions()
+	limits := &limitFlags{}
+	fs.DurationVar(&limits.timeout, "timeout", defaults.Timeout, "Maximum compile time per document (0 for no limit)")
+	fs.Int64Var(&limits.maxSize, "max-size", defaults.MaxFileSize, "Maximum input size in bytes")
+	return limits
+}
+
+// options returns converter options for templateFile ("" for raw Typst) with the limits
+func (l *limitFlags) options(templateFile string) (*mdpdf.Options, error) {
+	if l.timeout < 0 {
+		return nil, usageError("-timeout must not be negative (got %v)", l.timeout)
+	}
+	if l.maxSize <= 0 {
+		return nil, usageError("-max-size must be positive (got %d)", l.maxSize)
+	}
+
+	opts := mdpdf.DefaultOptions()
+	opts.TemplatePath = templateFile
+	opts.MaxFileSize = l.maxSize
+	opts.Timeout = l.timeout
+	return opts, nil
+}
+
+// converter creates a converter for templateFile with the limits
+func (l *limitFlags) converter(templateFile string) (*mdpdf.Converter, error) {
+	opts, err := l.options(templateFile)
+	if err != nil {
+		return nil, err
+	}
+	return mdpdf.NewConverter(opts)
+}
+
+// describe explains context errors of a conversion, which are otherwise
+// reported as a bare "context deadline exceeded" or "context canceled"
+func (l *limitFlags) describe(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("conversion timed out after %v: %w", l.timeout, err)
+	case errors.Is(err, context.Canceled):
+		return fmt.Errorf("conversion interrupted: %w", err)
+	default:
+		return err
+	}
+}
+
+// interruptContext returns a context cancelled by Ctrl-C or SIGTERM. After the
+// first signal the default handling is restored, so a second Ctrl-C exits at once.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
@@ -4,103 +4,127 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
-	"time"
 
-	"github.com/francescoalemanno/gotypst"
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/server"
 )
 
-func main() {
-	var (
-		inputFile    = flag.String("input", "", "Input markdown file (required)")
-		outputFile   = flag.String("output", "", "Output PDF file (optional, defaults to input.pdf)")
-		templateFile = flag.String("template", "exam-template.typ", "Template file path")
-		help         = flag.Bool("help", false, "Show help")
-	)
+// stringList is a flag that can be given multiple times
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// command is a CLI subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
 
-	flag.Parse()
+var commands = []command{
+	{"convert", "Convert markdown files to PDF", runConvert},
+	{"watch", "Rebuild a PDF whenever its markdown, template or assets change", runWatch},
+	{"validate", "Check that templates contain the placeholder and compile", runValidate},
+	{"lint", "Check that markdown files convert, without writing PDFs", runLint},
+	{"templates", "List templates or show one (templates list | templates show <name>)", runTemplates},
+	{"render-typst", "Compile a Typst source file to PDF", runRenderTypst},
+	{"serve", "Run the HTTP service (same flags as md-pdf-service)", runServe},
+}
 
-	if *help || *inputFile == "" {
+func main() {
+	// Compile worker subprocess started by the service's process isolation
+	if len(os.Args) > 1 && os.Args[1] == server.WorkerCommand {
+		if err := server.RunCompileWorker(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "compile worker:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	args := os.Args[1:]
+	if len(args) == 0 || args[0] == "help" || args[0] == "-help" || args[0] == "-h" || args[0] == "--help" {
 		showHelp()
 		return
 	}
 
-	// Determine output file
-	output := *outputFile
-	if output == "" {
-		ext := filepath.Ext(*inputFile)
-		output = strings.TrimSuffix(*inputFile, ext) + ".pdf"
+	// Flags without a command keep the original "md-pdf-cli -input file.md" usage
+	name := "convert"
+	if !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	// Convert
-	if err := convertMarkdownToPDF(*inputFile, output, *templateFile); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(args); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			return
+		}
 	}
 
-	fmt.Printf("✅ PDF generated successfully: %s\n", output)
+	fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", name)
+	showHelp()
+	os.Exit(exitUsage)
 }
 
 func showHelp() {
 	fmt.Println("Markdown to PDF Converter (CLI)")
 	fmt.Println("")
 	fmt.Println("Usage:")
-	fmt.Println("  md-pdf-cli -input <markdown-file> [options]")
+	fmt.Println("  md-pdf-cli <command> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Println("")
+	fmt.Println("Run \"md-pdf-cli <command> -help\" for the options of a command.")
+	fmt.Println("convert, lint, validate and render-typst print a JSON report with -json.")
 	fmt.Println("")
-	fmt.Println("Options:")
-	fmt.Println("  -input <file>      Input markdown file (required)")
-	fmt.Println("  -output <file>     Output PDF file (optional)")
-	fmt.Println("  -template <file>   Template file path (default: exam-template.typ)")
-	fmt.Println("  -help              Show this help")
+	fmt.Println("Exit codes:")
+	fmt.Println("  0    success")
+	fmt.Println("  1    unexpected error")
+	fmt.Println("  2    usage error (unknown command, invalid flags or arguments)")
+	fmt.Println("  3    template error (template missing, unreadable or without placeholder)")
+	fmt.Println("  4    compile error (Typst failed, input or output limit exceeded, or Typst input rejected)")
+	fmt.Println("  5    timeout (-timeout, 30s by default)")
+	fmt.Println("  6    I/O error (reading an input or writing an output failed)")
+	fmt.Println("  130  interrupted by Ctrl-C (partial outputs are removed)")
+	fmt.Println("  With several documents, the code of the first failed document is used.")
 	fmt.Println("")
 	fmt.Println("Examples:")
-	fmt.Println("  md-pdf-cli -input test.md")
-	fmt.Println("  md-pdf-cli -input test.md -output my-exam.pdf")
-	fmt.Println("  md-pdf-cli -input test.md -template custom-template.typ")
+	fmt.Println("  md-pdf-cli convert -input test.md")
+	fmt.Println("  md-pdf-cli convert -input test.md -output my-exam.pdf -template custom-template.typ")
+	fmt.Println("  md-pdf-cli convert -input part1.md -input part2.md -toc -output booklet.pdf")
+	fmt.Println("  pandoc notes.docx -t gfm | md-pdf-cli convert -input - -output - | lpr")
+	fmt.Println("  md-pdf-cli convert -r -outdir pdf -j 4 exams/ 'extra/*.md'")
+	fmt.Println("  md-pdf-cli watch -input exam.md -template custom-template.typ")
+	fmt.Println("  md-pdf-cli validate templates/*.typ")
+	fmt.Println("  md-pdf-cli lint exams/*.md")
+	fmt.Println("  md-pdf-cli convert -json -outdir pdf exams/ > report.json")
+	fmt.Println("  md-pdf-cli templates show exam-template")
+	fmt.Println("  md-pdf-cli render-typst -input poster.typ")
+	fmt.Println("  md-pdf-cli serve -port 8080")
 }
 
-func convertMarkdownToPDF(inputFile, outputFile, templateFile string) error {
-	// Read markdown content
-	markdownContent, err := os.ReadFile(inputFile)
-	if err != nil {
-		return fmt.Errorf("failed to read input file: %w", err)
-	}
-
-	// Read template
-	templateContent, err := os.ReadFile(templateFile)
-	if err != nil {
-		return fmt.Errorf("failed to read template file: %w", err)
-	}
-
-	// Replace placeholder with markdown content
-	templateStr := string(templateContent)
-	if !strings.Contains(templateStr, "{{Placeholder Markdown}}") {
-		return fmt.Errorf("template must contain {{Placeholder Markdown}} placeholder")
-	}
-
-	typstContent := strings.Replace(templateStr, "{{Placeholder Markdown}}", string(markdownContent), 1)
-
-	// Convert to PDF
-	fmt.Printf("🔄 Converting %s to PDF...\n", inputFile)
-	startTime := time.Now()
-
-	pdfBytes, err := gotypst.PDF([]byte(typstContent))
-	duration := time.Since(startTime)
-
-	if err != nil {
-		return fmt.Errorf("typst compilation failed: %w", err)
-	}
-
-	if len(pdfBytes) == 0 {
-		return fmt.Errorf("generated PDF is empty")
-	}
-
-	// Write PDF file
-	if err := os.WriteFile(outputFile, pdfBytes, 0644); err != nil {
-		return fmt.Errorf("failed to write PDF file: %w", err)
+// newFlagSet creates the flag set of a command with a usage line
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage:\n  md-pdf-cli %s\n\nOptions:\n", usage)
+		fs.PrintDefaults()
 	}
+	return fs
+}
 
-	fmt.Printf("📄 Generated %d bytes in %v\n", len(pdfBytes), duration)
+// runServe starts the HTTP service
+func runServe(args []string) error {
+	server.Serve(flag.NewFlagSet("serve", flag.ExitOnError), args, false)
 	return nil
 }
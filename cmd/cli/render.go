@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// runRenderTypst compiles a Typst source file to PDF under the raw Typst policy
+func runRenderTypst(args []string) error {
+	fs := newFlagSet("render-typst", "render-typst -input <typst-file> [options]")
+	var (
+		inputFile  = fs.String("input", "", "Input Typst file, \"-\" for standard input (required)")
+		outputFile = fs.String("output", "", "Output PDF file, \"-\" for standard output (optional, defaults to input.pdf, or standard output for standard input)")
+		packages   = fs.String("allow-packages", "", "Comma separated packages the source may import, e.g. @preview/cetz (empty for all)")
+		jsonOutput = fs.Bool("json", false, "Print a JSON report")
+	)
+	limits := addLimitFlags(fs)
+	write := addWriteFlags(fs, true)
+	fs.Parse(args)
+
+	if *inputFile == "" {
+		fs.Usage()
+		return usageError("missing -input")
+	}
+
+	output := *outputFile
+	if output == "" {
+		output = strings.TrimSuffix(*inputFile, filepath.Ext(*inputFile)) + ".pdf"
+		if *inputFile == stdioPath {
+			output = stdioPath
+		}
+	}
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+	if err := write.checkClobber(output); err != nil {
+		return err
+	}
+	if *jsonOutput && output == stdioPath {
+		return usageError("-json cannot be combined with writing the PDF to standard output")
+	}
+
+	opts, err := limits.options("")
+	if err != nil {
+		return err
+	}
+	if *packages != "" {
+		opts.RawTypst.AllowedPackages = strings.Split(*packages, ",")
+	}
+	converter, err := mdpdf.NewConverter(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	typstContent, err := readInput(*inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "🔄 Compiling %s to PDF...\n", inputName(*inputFile))
+	startTime := time.Now()
+	// Relative paths resolve against the directory of the input, or the working
+	// directory for standard input
+	result, err := converter.ConvertTypstResult(ctx, string(typstContent), mdpdf.WithBaseDir(filepath.Dir(*inputFile)))
+	err = limits.describe(err)
+	duration := time.Since(startTime)
+	if err == nil {
+		if err = write.writeOutput(output, result.PDF); err != nil {
+			err = fmt.Errorf("failed to write PDF file: %w", err)
+		}
+	}
+
+	if *jsonOutput {
+		status := statusConverted
+		if err != nil {
+			status = statusFailed
+		}
+		printJSON([]documentReport{newDocumentReport(*inputFile, output, status, result, duration, err)}, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "✅ PDF generated successfully: %s\n", outputName(output))
+	return nil
+}
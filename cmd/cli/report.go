@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// Exit codes, listed in the help output
+const (
+	exitOK       = 0
+	exitFailure  = 1 // unexpected errors
+	exitUsage    = 2 // invalid command line
+	exitTemplate = 3 // template missing, unreadable or without placeholder
+	exitCompile  = 4 // Typst compilation failed or the document was rejected
+	exitTimeout  = 5 // conversion timed out
+	exitIO       = 6 // reading an input or writing an output failed
+
+	exitInterrupted = 130 // stopped by Ctrl-C, as shells report SIGINT
+)
+
+// exitError is an error with the exit code it should produce
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// usageError reports an invalid command line
+func usageError(format string, args ...interface{}) error {
+	return &exitError{exitUsage, fmt.Errorf(format, args...)}
+}
+
+// exitCode maps an error to the exit code of its kind
+func exitCode(err error) int {
+	var exitErr *exitError
+	var templateErr *mdpdf.TemplateError
+	var compileErr *mdpdf.CompileError
+	var limitErr *mdpdf.LimitError
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	var syscallErr *os.SyscallError
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &exitErr):
+		return exitErr.code
+	case errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.As(err, &templateErr):
+		return exitTemplate
+	case errors.As(err, &compileErr), errors.As(err, &limitErr), errors.Is(err, mdpdf.ErrRawTypstNotAllowed):
+		return exitCompile
+	case errors.As(err, &pathErr), errors.As(err, &linkErr), errors.As(err, &syscallErr), errors.Is(err, mdpdf.ErrOutputExists):
+		return exitIO
+	default:
+		return exitFailure
+	}
+}
+
+// errorKind names the kind of error behind an exit code in JSON output
+func errorKind(code int) string {
+	switch code {
+	case exitUsage:
+		return "usage"
+	case exitTemplate:
+		return "template"
+	case exitCompile:
+		return "compile"
+	case exitTimeout:
+		return "timeout"
+	case exitIO:
+		return "io"
+	case exitInterrupted:
+		return "interrupted"
+	default:
+		return "error"
+	}
+}
+
+// documentReport describes the result for one document in -json output
+type documentReport struct {
+	Input       string             `json:"input"`
+	Output      string             `json:"output,omitempty"`
+	Status      string             `json:"status"`
+	Bytes       int                `json:"bytes,omitempty"`
+	Pages       int                `json:"pages,omitempty"`
+	DurationMs  int64              `json:"durationMs"`
+	Error       string             `json:"error,omitempty"`
+	ErrorKind   string             `json:"errorKind,omitempty"`
+	Diagnostics []mdpdf.Diagnostic `json:"diagnostics,omitempty"`
+	Warnings    []mdpdf.Diagnostic `json:"warnings,omitempty"`
+}
+
+// newDocumentReport describes a converted, skipped or failed document; result
+// is nil if nothing was compiled
+func newDocumentReport(input, output, status string, result *mdpdf.ConvertResult, duration time.Duration, err error) documentReport {
+	report := documentReport{
+		Input:      input,
+		Output:     output,
+		Status:     status,
+		DurationMs: duration.Milliseconds(),
+	}
+	if result != nil {
+		report.Bytes = len(result.PDF)
+		report.Warnings = result.Warnings
+		if len(result.PDF) > 0 {
+			report.Pages = mdpdf.CountPages(result.PDF)
+		}
+	}
+	if err != nil {
+		report.Error = err.Error()
+		report.ErrorKind = errorKind(exitCode(err))
+		var compileErr *mdpdf.CompileError
+		if errors.As(err, &compileErr) {
+			report.Diagnostics = compileErr.Diagnostics
+		} else {
+			report.Diagnostics = mdpdf.ParseDiagnostics(err.Error())
+		}
+	}
+	return report
+}
+
+// printJSON writes the -json output of a command to standard output
+func printJSON(documents []documentReport, err error) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(struct {
+		Documents []documentReport `json:"documents"`
+		ExitCode  int              `json:"exitCode"`
+	}{documents, exitCode(err)})
+}
+
+// firstFailure returns an error for a run with failed documents, carrying the
+// exit code of the first failure
+func firstFailure(failed, total int, errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return &exitError{exitCode(err), fmt.Errorf("%d of %d documents failed", failed, total)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+func TestExitCode(t *testing.T) {
+	_, pathErr := os.ReadFile("does-not-exist.md")
+	linkErr := os.Rename("does-not-exist.pdf", "out.pdf")
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, exitOK},
+		{"usage", usageError("missing -input"), exitUsage},
+		{"template", &mdpdf.TemplateError{Err: errors.New("no placeholder")}, exitTemplate},
+		{"compile", &mdpdf.CompileError{Err: errors.New("error: unclosed delimiter")}, exitCompile},
+		{"limit", fmt.Errorf("convert: %w", &mdpdf.LimitError{Limit: mdpdf.LimitPages, Max: 1, Actual: 2}), exitCompile},
+		{"raw typst", fmt.Errorf("%w: packages disabled", mdpdf.ErrRawTypstNotAllowed), exitCompile},
+		{"timeout", fmt.Errorf("conversion: %w", context.DeadlineExceeded), exitTimeout},
+		{"interrupted", (&limitFlags{}).describe(context.Canceled), exitInterrupted},
+		{"input size", &mdpdf.LimitError{Limit: mdpdf.LimitInputBytes, Max: 1, Actual: 2}, exitCompile},
+		{"io", fmt.Errorf("failed to read input file: %w", pathErr), exitIO},
+		{"rename", fmt.Errorf("failed to write output: %w", linkErr), exitIO},
+		{"syscall", fmt.Errorf("failed to write output: %w", os.NewSyscallError("fsync", syscall.EIO)), exitIO},
+		{"other", errors.New("boom"), exitFailure},
+		{"unknown template", runTemplates([]string{"show", "does-not-exist"}), exitTemplate},
+		{"template name missing", runTemplates([]string{"show"}), exitUsage},
+		{"templates command", runTemplates([]string{"remove"}), exitUsage},
+		{"batch", firstFailure(1, 2, []error{nil, &mdpdf.TemplateError{Err: errors.New("x")}}), exitTemplate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+
+	if err := firstFailure(0, 2, []error{nil, nil}); err != nil {
+		t.Errorf("Expected no failure, got %v", err)
+	}
+}
+
+func TestDocumentReport(t *testing.T) {
+	warnings := []mdpdf.Diagnostic{{Severity: "warning", Message: "unknown font family: nosuchfont", Line: 1, Column: 16}}
+	report := newDocumentReport("a.md", "a.pdf", statusConverted, &mdpdf.ConvertResult{PDF: []byte("%PDF"), Warnings: warnings}, time.Second, nil)
+	if report.Bytes != 4 || len(report.Warnings) != 1 || report.Warnings[0].Message != warnings[0].Message {
+		t.Errorf("Expected the size and warnings of the result, got %+v", report)
+	}
+
+	report = newDocumentReport("b.md", "b.pdf", statusFailed, nil, time.Second, &mdpdf.CompileError{Err: errors.New("boom")})
+	if report.Bytes != 0 || report.Warnings != nil || report.ErrorKind != "compile" {
+		t.Errorf("Unexpected report of a failed document: %+v", report)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// stdioPath as -input or -output means standard input or output
+const stdioPath = "-"
+
+// inputName names an input in messages
+func inputName(path string) string {
+	if path == stdioPath {
+		return "standard input"
+	}
+	return path
+}
+
+// outputName names an output in messages
+func outputName(path string) string {
+	if path == stdioPath {
+		return "standard output"
+	}
+	return path
+}
+
+// readParts reads markdown inputs, where stdioPath reads standard input
+func readParts(paths []string) ([]mdpdf.Part, error) {
+	parts := make([]mdpdf.Part, 0, len(paths))
+	readStdin := false
+	for _, path := range paths {
+		if path != stdioPath {
+			filed, err := mdpdf.PartsFromFiles([]string{path})
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, filed...)
+			continue
+		}
+
+		if readStdin {
+			return nil, errors.New("standard input can only be read once")
+		}
+		readStdin = true
+		content, err := readInput(stdioPath)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, mdpdf.Part{Title: "Standard Input", Markdown: string(content)})
+	}
+	return parts, nil
+}
+
+// readInput reads a file, or standard input for stdioPath
+func readInput(path string) ([]byte, error) {
+	if path == stdioPath {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// fileMode is an octal file permission flag such as 0644
+type fileMode os.FileMode
+
+func (m *fileMode) String() string {
+	return fmt.Sprintf("%#o", os.FileMode(*m))
+}
+
+func (m *fileMode) Set(value string) error {
+	perm, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || perm == 0 || perm > 0777 {
+		return fmt.Errorf("invalid file mode %q, use octal permissions like 0644", value)
+	}
+	*m = fileMode(perm)
+	return nil
+}
+
+// writeFlags are the output file options of the commands writing PDFs
+type writeFlags struct {
+	noClobber bool
+	mode      fileMode
+}
+
+// addWriteFlags registers -mode, and -no-clobber if the command may refuse to overwrite
+func addWriteFlags(fs *flag.FlagSet, noClobber bool) *writeFlags {
+	w := &writeFlags{mode: fileMode(mdpdf.DefaultFileMode)}
+	fs.Var(&w.mode, "mode", "Octal `permissions` of written PDF files")
+	if noClobber {
+		fs.BoolVar(&w.noClobber, "no-clobber", false, "Fail instead of overwriting existing PDF files")
+	}
+	return w
+}
+
+// checkClobber fails early, before compiling, if -no-clobber protects path
+func (w *writeFlags) checkClobber(path string) error {
+	if !w.noClobber || path == stdioPath {
+		return nil
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%w: %s", mdpdf.ErrOutputExists, path)
+	}
+	return nil
+}
+
+// writeOutput writes a PDF to a file atomically, or to standard output for stdioPath
+func (w *writeFlags) writeOutput(path string, pdfBytes []byte) error {
+	if path == stdioPath {
+		_, err := os.Stdout.Write(pdfBytes)
+		return err
+	}
+	return mdpdf.WriteFile(path, pdfBytes, os.FileMode(w.mode), w.noClobber)
+}
+
+// checkOutput refuses to write a PDF to an interactive terminal
+func checkOutput(path string) error {
+	if path != stdioPath {
+		return nil
+	}
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return errors.New("refusing to write a PDF to a terminal, redirect standard output or use -output <file>")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// builtinTemplate stands in for the path of the embedded default template
+const builtinTemplate = "(built-in)"
+
+// runTemplates lists the available templates or prints one
+func runTemplates(args []string) error {
+	fs := newFlagSet("templates", "templates [options] list | show <name>")
+	var (
+		templateFile = fs.String("template", "", "Default template file path (default: the built-in template)")
+		templateDir  = fs.String("template-dir", "templates", "Directory of additional named templates")
+	)
+	fs.Parse(args)
+
+	templates := mdpdf.ListTemplates(*templateFile, *templateDir)
+
+	switch fs.Arg(0) {
+	case "list", "":
+		for _, t := range templates {
+			path := t.Path
+			if path == "" {
+				path = builtinTemplate
+			}
+			fmt.Printf("%-20s %s\n", t.Name, path)
+		}
+		return nil
+	case "show":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return usageError("templates show needs a template name")
+		}
+		for _, t := range templates {
+			if t.Name == fs.Arg(1) {
+				content, err := mdpdf.ReadTemplate(t.Path)
+				if err != nil {
+					return fmt.Errorf("failed to read template: %w", err)
+				}
+				_, err = os.Stdout.Write(content)
+				return err
+			}
+		}
+		return &exitError{exitTemplate, fmt.Errorf("unknown template %q", fs.Arg(1))}
+	default:
+		fs.Usage()
+		return usageError("unknown templates command %q", fs.Arg(0))
+	}
+}
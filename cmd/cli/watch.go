@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// assetReferencePattern matches files a markdown document pulls in: markdown images
+// and links, and the path arguments of Typst file functions in raw Typst
+var assetReferencePattern = regexp.MustCompile(`!?\[[^\]]*\]\(\s*<?([^)\s>]+)|\b(?:image|read|json|yaml|toml|csv|xml|include)\(\s*"([^"]+)"`)
+
+// fileState is what polling compares to notice a change
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, modTime: info.ModTime(), size: info.Size()}
+}
+
+// watcher rebuilds a PDF when its markdown, template or referenced assets change.
+// It polls instead of using file system events so it works in containers and on
+// network mounts.
+type watcher struct {
+	input        string
+	output       string
+	templateFile string
+	interval     time.Duration
+	debounce     time.Duration
+	converter    *mdpdf.Converter
+	limits       *limitFlags
+	write        *writeFlags
+
+	files map[string]fileState
+}
+
+// runWatch converts a markdown file and converts it again whenever its inputs change
+func runWatch(args []string) error {
+	fs := newFlagSet("watch", "watch -input <markdown-file> [options]")
+	var (
+		inputFile    = fs.String("input", "", "Input markdown file (required)")
+		outputFile   = fs.String("output", "", "Output PDF file (optional, defaults to input.pdf)")
+		templateFile = fs.String("template", "", "Template file path (default: the built-in template)")
+		interval     = fs.Duration("interval", 500*time.Millisecond, "How often files are checked for changes")
+		debounce     = fs.Duration("debounce", 300*time.Millisecond, "Quiet time after a change before rebuilding")
+	)
+	limits := addLimitFlags(fs)
+	write := addWriteFlags(fs, false)
+	fs.Parse(args)
+
+	if *inputFile == "" {
+		fs.Usage()
+		return usageError("missing -input")
+	}
+	if *interval <= 0 || *debounce < 0 {
+		return usageError("-interval must be positive and -debounce must not be negative")
+	}
+
+	output := *outputFile
+	if output == "" {
+		output = strings.TrimSuffix(*inputFile, filepath.Ext(*inputFile)) + ".pdf"
+	}
+
+	converter, err := limits.converter(*templateFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	w := &watcher{
+		input:        *inputFile,
+		output:       output,
+		templateFile: *templateFile,
+		interval:     *interval,
+		debounce:     *debounce,
+		converter:    converter,
+		limits:       limits,
+		write:        write,
+	}
+	w.run(ctx)
+	return nil
+}
+
+// run builds once, then polls until ctx is done
+func (w *watcher) run(ctx context.Context) {
+	w.build(ctx)
+	fmt.Printf("👀 Watching %s (Ctrl-C to stop)\n", strings.Join(w.watchedFiles(), ", "))
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var changedAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("👋 Stopped watching")
+			return
+		case <-ticker.C:
+		}
+
+		if changed := w.poll(); len(changed) > 0 {
+			fmt.Printf("✏️  Changed: %s\n", strings.Join(changed, ", "))
+			changedAt = time.Now()
+		}
+
+		// Wait until changes settle, e.g. an editor writing several files
+		if !changedAt.IsZero() && time.Since(changedAt) >= w.debounce {
+			changedAt = time.Time{}
+			w.build(ctx)
+		}
+	}
+}
+
+// build converts the document and writes the PDF only on success, so the last
+// good PDF stays in place while the document is broken. It then updates the
+// watched files, as the markdown may reference different assets. Stopping the
+// watcher cancels a build in progress.
+func (w *watcher) build(ctx context.Context) {
+	// Record file states before reading them, so edits during the build trigger another one
+	states := map[string]fileState{w.input: statFile(w.input)}
+	if w.templateFile != "" {
+		states[w.templateFile] = statFile(w.templateFile)
+	}
+
+	startTime := time.Now()
+	markdown, err := os.ReadFile(w.input)
+	if err == nil {
+		for _, asset := range referencedAssets(string(markdown), filepath.Dir(w.input)) {
+			if _, ok := states[asset]; !ok {
+				states[asset] = statFile(asset)
+			}
+		}
+
+		var result *mdpdf.ConvertResult
+		result, err = compileMarkdown(ctx, w.converter, w.limits, filepath.Dir(w.input), string(markdown))
+		if err == nil {
+			err = w.write.writeOutput(w.output, result.PDF)
+		}
+	}
+	w.files = states
+
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ %s build failed, keeping the previous PDF:\n%v\n", time.Now().Format("15:04:05"), err)
+		return
+	}
+	fmt.Printf("✅ %s rebuilt %s in %v\n", time.Now().Format("15:04:05"), w.output, time.Since(startTime).Round(time.Millisecond))
+}
+
+// poll updates the file states and returns the files that changed
+func (w *watcher) poll() []string {
+	var changed []string
+	for path, old := range w.files {
+		if state := statFile(path); state != old {
+			w.files[path] = state
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// watchedFiles returns the watched paths in order
+func (w *watcher) watchedFiles() []string {
+	paths := make([]string, 0, len(w.files))
+	for path := range w.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// referencedAssets returns the local files referenced by markdown, resolved against baseDir
+func referencedAssets(markdown, baseDir string) []string {
+	seen := map[string]bool{}
+	var assets []string
+	for _, match := range assetReferencePattern.FindAllStringSubmatch(markdown, -1) {
+		ref := match[1] + match[2]
+		if ref == "" || strings.Contains(ref, "://") || strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, "mailto:") {
+			continue
+		}
+		if !filepath.IsAbs(ref) {
+			ref = filepath.Join(baseDir, ref)
+		}
+		if !seen[ref] {
+			seen[ref] = true
+			assets = append(assets, ref)
+		}
+	}
+	return assets
+}
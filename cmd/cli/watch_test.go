@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReferencedAssets(t *testing.T) {
+	markdown := "![Plot](figures/plot.png) and [data](data.csv), [site](https://example.com), [top](#top)\n" +
+		"<!--raw-typst #image(\"logo.svg\", width: 2cm) -->\n![Again](figures/plot.png)"
+
+	got := referencedAssets(markdown, "exams")
+	want := []string{
+		filepath.Join("exams", "figures/plot.png"),
+		filepath.Join("exams", "data.csv"),
+		filepath.Join("exams", "logo.svg"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestWatcherPoll(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "exam.md", "logo.png")
+	exam, logo := filepath.Join(dir, "exam.md"), filepath.Join(dir, "logo.png")
+
+	w := &watcher{files: map[string]fileState{exam: statFile(exam), logo: statFile(logo)}}
+	if changed := w.poll(); len(changed) != 0 {
+		t.Fatalf("Expected no changes, got %v", changed)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(logo, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(exam); err != nil {
+		t.Fatal(err)
+	}
+	if changed := w.poll(); !reflect.DeepEqual(changed, []string{exam, logo}) {
+		t.Fatalf("Expected both files to change, got %v", changed)
+	}
+	if changed := w.poll(); len(changed) != 0 {
+		t.Fatalf("Expected changes to be reported once, got %v", changed)
+	}
+}
+
+func TestWatcherBuildWatchesTemplate(t *testing.T) {
+	dir := t.TempDir()
+	exam, template := filepath.Join(dir, "exam.md"), filepath.Join(dir, "exam.typ")
+
+	// The built-in template is not a file to watch
+	w := &watcher{input: exam}
+	w.build(context.Background())
+	if got := w.watchedFiles(); !reflect.DeepEqual(got, []string{exam}) {
+		t.Fatalf("Expected only the input to be watched, got %v", got)
+	}
+
+	w = &watcher{input: exam, templateFile: template}
+	w.build(context.Background())
+	if got := w.watchedFiles(); !reflect.DeepEqual(got, []string{exam, template}) {
+		t.Fatalf("Expected the input and template to be watched, got %v", got)
+	}
+}
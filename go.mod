@@ -1,6 +1,5 @@
 module github.com/mabixdev/GoTypstMdToPDF
 
-
 go 1.21
 
 require (
@@ -8,6 +7,7 @@ require (
 	github.com/gin-contrib/cors v1.7.0
 	github.com/gin-contrib/static v1.1.2
 	github.com/gin-gonic/gin v1.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -37,5 +37,4 @@ require (
 	golang.org/x/sys v0.20.0 // indirect
 	golang.org/x/text v0.15.0 // indirect
 	google.golang.org/protobuf v1.34.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -3,36 +3,64 @@ package mdpdf
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
-
-	"github.com/francescoalemanno/gotypst"
 )
 
-// Converter handles markdown to PDF conversions
+// Converter handles markdown to PDF conversions. It is safe for concurrent use:
+// its configuration is fixed by NewConverter, and ConvertOptions only affect the
+// call they are passed to.
 type Converter struct {
-	templatePath string
-	options      *Options
+	options *Options
 }
 
 // Options configures the conversion process
 type Options struct {
-	// TemplatePath is the path to the Typst template file
+	// TemplatePath is the path to the Typst template file (default: the embedded
+	// DefaultTemplate)
 	TemplatePath string
+	// Template is the template content. It takes precedence over TemplatePath,
+	// which then only names the template.
+	Template []byte
+	// TemplateFS is the file system TemplatePath is read from (default: the OS file system)
+	TemplateFS fs.FS
 	// MaxFileSize limits the input markdown size (default: 50MB)
 	MaxFileSize int64
-	// Timeout sets the maximum conversion time (default: 30s)
+	// Timeout sets the maximum conversion time when the context has no deadline (default: 30s, 0 = none)
 	Timeout time.Duration
+	// MaxPages limits the page count of the generated PDF (0 = unlimited)
+	MaxPages int
+	// MaxOutputBytes limits the size of the generated PDF (0 = unlimited)
+	MaxOutputBytes int64
+	// RawTypst decides whether ConvertTypst accepts Typst source and markdown may
+	// embed Typst in <!--raw-typst--> comments (default: allowed)
+	RawTypst RawTypstPolicy
+	// FileMode sets the permissions of PDF files written by the *ToFile methods (default: 0644)
+	FileMode os.FileMode
+	// NoOverwrite makes the *ToFile methods fail with ErrOutputExists instead of
+	// replacing an existing output file
+	NoOverwrite bool
+	// Variables are passed to the template, see WithVariables
+	Variables map[string]string
+	// Format sets the page format, see WithFormat (default: the template's)
+	Format string
+	// Metadata sets the PDF document information, see WithMetadata
+	Metadata *Metadata
+	// BaseDir is the directory relative paths in documents resolve against, see
+	// WithBaseDir (default: the system temp directory)
+	BaseDir string
 }
 
 // DefaultOptions returns sensible default options
 func DefaultOptions() *Options {
 	return &Options{
-		TemplatePath: "exam-template.typ",
-		MaxFileSize:  50 * 1024 * 1024, // 50MB
-		Timeout:      30 * time.Second,
+		MaxFileSize: 50 * 1024 * 1024, // 50MB
+		Timeout:     30 * time.Second,
 	}
 }
 
@@ -42,144 +70,287 @@ func NewConverter(opts *Options) (*Converter, error) {
 		opts = DefaultOptions()
 	}
 
+	if err := opts.RawTypst.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Validate template exists
-	if _, err := os.Stat(opts.TemplatePath); err != nil {
-		return nil, fmt.Errorf("template file not found: %w", err)
+	if err := checkTemplateFile(opts); err != nil {
+		return nil, err
 	}
 
-	return &Converter{
-		templatePath: opts.TemplatePath,
-		options:      opts,
-	}, nil
+	// Copy the options so later changes by the caller cannot race with conversions
+	options := *opts
+	if opts.Template != nil {
+		options.Template = append([]byte(nil), opts.Template...)
+	}
+	options.RawTypst.AllowedPackages = append([]string(nil), opts.RawTypst.AllowedPackages...)
+	options.Variables = make(map[string]string, len(opts.Variables))
+	for name, value := range opts.Variables {
+		options.Variables[name] = value
+	}
+	if opts.Metadata != nil {
+		metadata := *opts.Metadata
+		options.Metadata = &metadata
+	}
+
+	return &Converter{options: &options}, nil
 }
 
 // ConvertFromString converts markdown string to PDF bytes
-func (c *Converter) ConvertFromString(ctx context.Context, markdownContent string) ([]byte, error) {
+func (c *Converter) ConvertFromString(ctx context.Context, markdownContent string, opts ...ConvertOption) ([]byte, error) {
+	res, err := c.ConvertFromStringResult(ctx, markdownContent, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return res.PDF, nil
+}
+
+// ConvertFromStringResult converts markdown string to PDF and reports the page
+// count, compile time, template and warnings along with it
+func (c *Converter) ConvertFromStringResult(ctx context.Context, markdownContent string, opts ...ConvertOption) (*ConvertResult, error) {
+	s := c.settings(opts)
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	typstContent, templateName, templateContent, err := c.render(ctx, s, markdownContent)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.compile(ctx, s, typstContent)
+	if err != nil {
+		return nil, err
+	}
+	res.TemplateName = templateName
+	res.TemplateHash = TemplateHash(templateContent)
+	return res, nil
+}
+
+// RenderMarkdown returns the Typst source ConvertFromString compiles for
+// markdown: the template with the markdown in place of its placeholder. Like
+// ConvertFromString it applies MaxFileSize and the RawTypst policy, so callers
+// that compile the source themselves enforce the same rules.
+func (c *Converter) RenderMarkdown(ctx context.Context, markdownContent string, opts ...ConvertOption) (string, error) {
+	typstContent, _, _, err := c.render(ctx, c.settings(opts), markdownContent)
+	return typstContent, err
+}
+
+// render checks markdown and substitutes it into the template of the call. It
+// returns the Typst source and the name and content of the template.
+func (c *Converter) render(ctx context.Context, s *convertSettings, markdownContent string) (string, string, []byte, error) {
 	// Check if context is already cancelled
 	select {
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return "", "", nil, ctx.Err()
 	default:
 	}
 
 	// Validate input size
-	if int64(len(markdownContent)) > c.options.MaxFileSize {
-		return nil, fmt.Errorf("content exceeds maximum size limit (%d bytes)", c.options.MaxFileSize)
+	if err := c.checkInputSize(markdownContent); err != nil {
+		return "", "", nil, err
 	}
 
 	// Read template
-	templateContent, err := os.ReadFile(c.templatePath)
+	templateName, templateContent, err := s.loadTemplate()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read template: %w", err)
+		return "", "", nil, err
 	}
 
-	// Check context again before processing
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	default:
+	// Replace placeholder
+	typstContent, markdownIsTypst, err := renderTemplate(string(templateContent), markdownContent)
+	if err != nil {
+		return "", "", nil, err
 	}
 
-	// Replace placeholder
-	templateStr := string(templateContent)
-	if !strings.Contains(templateStr, "{{Placeholder Markdown}}") {
-		return nil, fmt.Errorf("template must contain {{Placeholder Markdown}} placeholder")
+	// Typst code in the markdown is subject to the raw Typst policy: all of it
+	// if the template compiles the markdown as Typst, else its raw-typst comments
+	if markdownIsTypst {
+		err = c.options.RawTypst.Check(markdownContent, IsAuthenticatedCaller(ctx))
+	} else {
+		err = c.options.RawTypst.CheckMarkdown(markdownContent, IsAuthenticatedCaller(ctx))
+	}
+	if err != nil {
+		return "", "", nil, err
 	}
+	return typstContent, templateName, templateContent, nil
+}
 
-	typstContent := strings.Replace(templateStr, "{{Placeholder Markdown}}", markdownContent, 1)
+// ConvertTypst compiles user-supplied Typst source to PDF bytes, subject to the
+// RawTypst policy. Mark ctx with WithAuthenticatedCaller for authenticated callers.
+func (c *Converter) ConvertTypst(ctx context.Context, typstContent string, opts ...ConvertOption) ([]byte, error) {
+	res, err := c.ConvertTypstResult(ctx, typstContent, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return res.PDF, nil
+}
 
-	// Convert to PDF with context handling
-	// Since gotypst.PDF doesn't support context, we'll use a goroutine with timeout
-	type result struct {
-		pdfBytes []byte
-		err      error
+// ConvertTypstResult is ConvertTypst reporting the page count, compile time and
+// warnings along with the PDF
+func (c *Converter) ConvertTypstResult(ctx context.Context, typstContent string, opts ...ConvertOption) (*ConvertResult, error) {
+	s := c.settings(opts)
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	if err := c.CheckTypst(ctx, typstContent); err != nil {
+		return nil, err
 	}
 
-	resultChan := make(chan result, 1)
-	go func() {
-		pdfBytes, err := gotypst.PDF([]byte(typstContent))
-		resultChan <- result{pdfBytes: pdfBytes, err: err}
-	}()
+	return c.compile(ctx, s, typstContent)
+}
 
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case res := <-resultChan:
-		if res.err != nil {
-			return nil, fmt.Errorf("typst compilation failed: %w", res.err)
-		}
+// CheckTypst returns the error ConvertTypst rejects typstContent with before
+// compiling it: a *LimitError beyond MaxFileSize or an error wrapping
+// ErrRawTypstNotAllowed under the RawTypst policy
+func (c *Converter) CheckTypst(ctx context.Context, typstContent string) error {
+	if err := c.checkInputSize(typstContent); err != nil {
+		return err
+	}
+	return c.options.RawTypst.Check(typstContent, IsAuthenticatedCaller(ctx))
+}
 
-		if len(res.pdfBytes) == 0 {
-			return nil, fmt.Errorf("generated PDF is empty")
+// checkInputSize returns a *LimitError if content is larger than MaxFileSize
+func (c *Converter) checkInputSize(content string) error {
+	if int64(len(content)) > c.options.MaxFileSize {
+		return &LimitError{Limit: LimitInputBytes, Max: c.options.MaxFileSize, Actual: int64(len(content))}
+	}
+	return nil
+}
+
+// compile turns Typst source into a PDF with the settings of the call and
+// applies the output limits
+func (c *Converter) compile(ctx context.Context, s *convertSettings, typstContent string) (*ConvertResult, error) {
+	prelude := s.prelude()
+	preludeLines := strings.Count(prelude, "\n")
+	args := s.compileArgs()
+
+	startTime := time.Now()
+	res, err := RunTypst(ctx, prelude+typstContent, s.root(), args)
+	duration := time.Since(startTime)
+	if err != nil {
+		// Report lines of the document rather than of the prelude plus document
+		var compileErr *CompileError
+		if errors.As(err, &compileErr) {
+			offsetDiagnostics(compileErr.Diagnostics, preludeLines)
 		}
+		return nil, err
+	}
+	offsetDiagnostics(res.Warnings, preludeLines)
+
+	if len(res.PDF) == 0 {
+		return nil, fmt.Errorf("generated PDF is empty")
+	}
 
-		return res.pdfBytes, nil
+	res.Pages, err = CheckOutputLimits(res.PDF, c.options.MaxPages, c.options.MaxOutputBytes)
+	if err != nil {
+		return nil, err
 	}
+	res.CompileDuration = duration
+	return res, nil
 }
 
 // ConvertFromFile converts markdown file to PDF bytes
-func (c *Converter) ConvertFromFile(ctx context.Context, inputPath string) ([]byte, error) {
+func (c *Converter) ConvertFromFile(ctx context.Context, inputPath string, opts ...ConvertOption) ([]byte, error) {
+	res, err := c.ConvertFromFileResult(ctx, inputPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return res.PDF, nil
+}
+
+// ConvertFromFileResult is ConvertFromFile returning a ConvertResult
+func (c *Converter) ConvertFromFileResult(ctx context.Context, inputPath string, opts ...ConvertOption) (*ConvertResult, error) {
 	markdownContent, err := os.ReadFile(inputPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read input file: %w", err)
 	}
 
-	return c.ConvertFromString(ctx, string(markdownContent))
+	// Relative paths in the file resolve against its directory unless a base
+	// directory is configured
+	if c.options.BaseDir == "" {
+		opts = append([]ConvertOption{WithBaseDir(filepath.Dir(inputPath))}, opts...)
+	}
+	return c.ConvertFromStringResult(ctx, string(markdownContent), opts...)
 }
 
 // ConvertFromFileToFile converts markdown file to PDF file
-func (c *Converter) ConvertFromFileToFile(ctx context.Context, inputPath, outputPath string) error {
-	pdfBytes, err := c.ConvertFromFile(ctx, inputPath)
-	if err != nil {
-		return err
+func (c *Converter) ConvertFromFileToFile(ctx context.Context, inputPath, outputPath string, opts ...ConvertOption) error {
+	// Fail before compiling if the output may not be replaced
+	if c.options.NoOverwrite {
+		if err := checkNotExists(outputPath); err != nil {
+			return err
+		}
 	}
 
-	if err := os.WriteFile(outputPath, pdfBytes, 0644); err != nil {
-		return fmt.Errorf("failed to write PDF file: %w", err)
+	pdfBytes, err := c.ConvertFromFile(ctx, inputPath, opts...)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return c.writePDF(outputPath, pdfBytes)
 }
 
 // ConvertFromStringToFile converts markdown string to PDF file
-func (c *Converter) ConvertFromStringToFile(ctx context.Context, markdownContent, outputPath string) error {
-	pdfBytes, err := c.ConvertFromString(ctx, markdownContent)
+func (c *Converter) ConvertFromStringToFile(ctx context.Context, markdownContent, outputPath string, opts ...ConvertOption) error {
+	if c.options.NoOverwrite {
+		if err := checkNotExists(outputPath); err != nil {
+			return err
+		}
+	}
+
+	pdfBytes, err := c.ConvertFromString(ctx, markdownContent, opts...)
 	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(outputPath, pdfBytes, 0644); err != nil {
+	return c.writePDF(outputPath, pdfBytes)
+}
+
+// writePDF writes a generated PDF atomically with the configured mode
+func (c *Converter) writePDF(outputPath string, pdfBytes []byte) error {
+	if err := WriteFile(outputPath, pdfBytes, c.options.FileMode, c.options.NoOverwrite); err != nil {
 		return fmt.Errorf("failed to write PDF file: %w", err)
 	}
-
 	return nil
 }
 
 // GetTemplateContent returns the current template content
 func (c *Converter) GetTemplateContent() (string, error) {
-	content, err := os.ReadFile(c.templatePath)
+	_, content, err := c.settings(nil).loadTemplate()
 	if err != nil {
-		return "", fmt.Errorf("failed to read template: %w", err)
+		return "", err
 	}
 	return string(content), nil
 }
 
 // ValidateTemplate checks if the template is valid
 func (c *Converter) ValidateTemplate() error {
-	content, err := c.GetTemplateContent()
+	return c.ValidateTemplateContext(context.Background())
+}
+
+// ValidateTemplateContext checks if the template is valid by compiling it with
+// minimal content, within Options.Timeout unless ctx has a deadline
+func (c *Converter) ValidateTemplateContext(ctx context.Context) error {
+	s := c.settings(nil)
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	_, content, err := s.loadTemplate()
 	if err != nil {
 		return err
 	}
 
-	if !strings.Contains(content, "{{Placeholder Markdown}}") {
-		return fmt.Errorf("template must contain {{Placeholder Markdown}} placeholder")
-	}
-
 	// Test compilation with minimal content
-	testContent := strings.Replace(content, "{{Placeholder Markdown}}", "# Test", 1)
-	_, err = gotypst.PDF([]byte(testContent))
+	testContent, _, err := renderTemplate(string(content), "# Test")
 	if err != nil {
-		return fmt.Errorf("template compilation test failed: %w", err)
+		return err
+	}
+	if _, err := c.compile(ctx, s, testContent); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return &TemplateError{fmt.Errorf("template compilation test failed: %w", err)}
 	}
 
 	return nil
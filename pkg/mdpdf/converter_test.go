@@ -2,13 +2,15 @@ package mdpdf
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
 
 func getTestOptions() *Options {
 	opts := DefaultOptions()
-	opts.TemplatePath = "../../exam-template.typ" // Adjust path for test
 	return opts
 }
 
@@ -75,3 +77,65 @@ func TestConvertFromStringWithCancelledContext(t *testing.T) {
 		t.Fatalf("Expected context.Canceled error, got: %v", err)
 	}
 }
+
+func TestConvertRelativeAssets(t *testing.T) {
+	dir := t.TempDir()
+	logo := `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"><rect width="10" height="10"/></svg>`
+	if err := os.WriteFile(filepath.Join(dir, "logo.svg"), []byte(logo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	markdown := `#image("logo.svg")`
+	input := filepath.Join(dir, "doc.md")
+	if err := os.WriteFile(input, []byte(markdown), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := getTestOptions()
+	opts.Template = []byte("{{Placeholder Markdown}}")
+	converter, err := NewConverter(opts)
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := converter.ConvertFromFileResult(ctx, input); err != nil {
+		t.Fatalf("Asset relative to the input file not found: %v", err)
+	}
+	if _, err := converter.ConvertFromStringResult(ctx, markdown, WithBaseDir(dir)); err != nil {
+		t.Fatalf("Asset relative to the base directory not found: %v", err)
+	}
+
+	var compileErr *CompileError
+	if _, err := converter.ConvertFromStringResult(ctx, markdown, WithBaseDir(t.TempDir())); !errors.As(err, &compileErr) {
+		t.Fatalf("Expected *CompileError for a missing asset, got %v", err)
+	}
+}
+
+func TestConvertTimeoutKillsCompiler(t *testing.T) {
+	// Temporary files of the compile go to TMPDIR, which must be empty afterwards
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	converter, err := NewConverter(getTestOptions())
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+
+	slowTypst := "#let n = 0\n#for i in range(100000000) { n += i }\n#n"
+	startTime := time.Now()
+	_, err = converter.ConvertTypst(context.Background(), slowTypst, WithTimeout(200*time.Millisecond))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded, got: %v", err)
+	}
+	if elapsed := time.Since(startTime); elapsed > 5*time.Second {
+		t.Fatalf("Compile took %v after the timeout", elapsed)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Fatalf("Compile left %d temporary files", len(entries))
+	}
+}
@@ -0,0 +1,66 @@
+package mdpdf
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// diagnosticPattern matches a Typst error or warning, possibly behind the
+// prefix of a wrapping error, and its optional source location
+var diagnosticPattern = regexp.MustCompile(`(?m)(?:^|: )(error|warning): ([^\n]*)(?:\n\s*┌─ [^\n]*?:(\d+):(\d+))?`)
+
+// TemplateError reports a template that cannot be read or lacks the placeholder
+type TemplateError struct {
+	Err error
+}
+
+func (e *TemplateError) Error() string { return e.Err.Error() }
+
+func (e *TemplateError) Unwrap() error { return e.Err }
+
+// Diagnostic is an error or warning reported by the Typst compiler. Line and
+// Column refer to the generated Typst source (template plus markdown, without
+// the rules of WithFormat and WithMetadata) and are zero when Typst gave no location.
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// CompileError reports a failed Typst compilation
+type CompileError struct {
+	Err         error
+	Diagnostics []Diagnostic
+}
+
+func newCompileError(err error) *CompileError {
+	return &CompileError{Err: err, Diagnostics: ParseDiagnostics(err.Error())}
+}
+
+func (e *CompileError) Error() string { return "typst compilation failed: " + e.Err.Error() }
+
+func (e *CompileError) Unwrap() error { return e.Err }
+
+// offsetDiagnostics moves the locations of diagnostics up by lines, the number of
+// lines placed before the document. Locations within those lines are dropped.
+func offsetDiagnostics(diagnostics []Diagnostic, lines int) {
+	for i := range diagnostics {
+		if d := &diagnostics[i]; d.Line > lines {
+			d.Line -= lines
+		} else {
+			d.Line, d.Column = 0, 0
+		}
+	}
+}
+
+// ParseDiagnostics extracts the errors and warnings from Typst compiler output
+func ParseDiagnostics(output string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, match := range diagnosticPattern.FindAllStringSubmatch(output, -1) {
+		line, _ := strconv.Atoi(match[3])
+		column, _ := strconv.Atoi(match[4])
+		diagnostics = append(diagnostics, Diagnostic{Severity: match[1], Message: match[2], Line: line, Column: column})
+	}
+	return diagnostics
+}
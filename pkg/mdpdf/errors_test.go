@@ -0,0 +1,49 @@
+package mdpdf
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	output := "error: unclosed delimiter\n  ┌─ ../237472747.typ:12:4\n  │\n12 │ #bad(\n  │     ^\n\n" +
+		"warning: unknown font family: foo\n\n exit status 1"
+
+	want := []Diagnostic{
+		{Severity: "error", Message: "unclosed delimiter", Line: 12, Column: 4},
+		{Severity: "warning", Message: "unknown font family: foo"},
+	}
+	if got := ParseDiagnostics(output); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %+v, got %+v", want, got)
+	}
+
+	wrapped := "template compilation test failed: " + output
+	if got := ParseDiagnostics(wrapped); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %+v from a wrapped error, got %+v", want, got)
+	}
+
+	err := error(newCompileError(errors.New(output)))
+	var compileErr *CompileError
+	if !errors.As(err, &compileErr) || len(compileErr.Diagnostics) != 2 {
+		t.Fatalf("Expected a CompileError with diagnostics, got %v", err)
+	}
+}
+
+func TestDiagnosticLinesSkipPrelude(t *testing.T) {
+	converter, err := NewConverter(DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+
+	_, err = converter.ConvertTypst(context.Background(), "= Title\n#undefined-name",
+		WithFormat("a5"), WithMetadata(Metadata{Title: "Report"}))
+	var compileErr *CompileError
+	if !errors.As(err, &compileErr) || len(compileErr.Diagnostics) == 0 {
+		t.Fatalf("Expected a CompileError with diagnostics, got %v", err)
+	}
+	if line := compileErr.Diagnostics[0].Line; line != 2 {
+		t.Fatalf("Expected the error on line 2 of the document, got line %d", line)
+	}
+}
@@ -0,0 +1,56 @@
+package mdpdf
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Limits reported by LimitError
+const (
+	LimitInputBytes  = "input_bytes"
+	LimitPages       = "pages"
+	LimitOutputBytes = "output_bytes"
+)
+
+// pageObjectPattern matches the type entry of a page object ("/Type /Page", not "/Type /Pages")
+var pageObjectPattern = regexp.MustCompile(`/Type\s*/Page\b`)
+
+// LimitError is returned when the input exceeds MaxFileSize or a generated PDF
+// exceeds MaxPages or MaxOutputBytes
+type LimitError struct {
+	Limit  string // LimitInputBytes, LimitPages or LimitOutputBytes
+	Max    int64
+	Actual int64
+}
+
+func (e *LimitError) Error() string {
+	switch e.Limit {
+	case LimitInputBytes:
+		return fmt.Sprintf("content exceeds maximum size limit (%d bytes)", e.Max)
+	case LimitPages:
+		return fmt.Sprintf("generated PDF has %d pages, exceeding the limit of %d", e.Actual, e.Max)
+	default:
+		return fmt.Sprintf("generated PDF is %d bytes, exceeding the limit of %d bytes", e.Actual, e.Max)
+	}
+}
+
+// CountPages returns the number of pages of a PDF by counting its page objects.
+// PDFs that keep page objects in compressed object streams are not supported;
+// Typst writes them uncompressed.
+func CountPages(pdf []byte) int {
+	return len(pageObjectPattern.FindAllIndex(pdf, -1))
+}
+
+// CheckOutputLimits returns a *LimitError if pdf is larger than maxBytes or has
+// more than maxPages pages. Zero disables a limit. It returns the page count.
+func CheckOutputLimits(pdf []byte, maxPages int, maxBytes int64) (int, error) {
+	if maxBytes > 0 && int64(len(pdf)) > maxBytes {
+		return 0, &LimitError{Limit: LimitOutputBytes, Max: maxBytes, Actual: int64(len(pdf))}
+	}
+
+	pages := CountPages(pdf)
+	if maxPages > 0 && pages > maxPages {
+		return pages, &LimitError{Limit: LimitPages, Max: int64(maxPages), Actual: int64(pages)}
+	}
+	return pages, nil
+}
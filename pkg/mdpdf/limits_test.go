@@ -0,0 +1,55 @@
+package mdpdf
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakePDF builds a minimal PDF body with the given number of page objects
+func fakePDF(pages int) []byte {
+	var b strings.Builder
+	b.WriteString("%PDF-1.7\n")
+	for i := 0; i < pages; i++ {
+		b.WriteString("<<\n  /Type /Page\n  /Parent 1 0 R\n>>\n")
+	}
+	b.WriteString("<<\n  /Type /Pages\n  /Count 3\n>>\n")
+	return []byte(b.String())
+}
+
+func TestCountPages(t *testing.T) {
+	if got := CountPages(fakePDF(3)); got != 3 {
+		t.Fatalf("Expected 3 pages, got %d", got)
+	}
+}
+
+func TestCheckOutputLimits(t *testing.T) {
+	pdf := fakePDF(3)
+
+	if pages, err := CheckOutputLimits(pdf, 3, int64(len(pdf))); err != nil || pages != 3 {
+		t.Fatalf("Expected PDF within limits, got %d pages, %v", pages, err)
+	}
+
+	var limitErr *LimitError
+	if _, err := CheckOutputLimits(pdf, 2, 0); !errors.As(err, &limitErr) || limitErr.Limit != LimitPages || limitErr.Actual != 3 {
+		t.Fatalf("Expected page limit error, got %v", err)
+	}
+	if _, err := CheckOutputLimits(pdf, 0, 10); !errors.As(err, &limitErr) || limitErr.Limit != LimitOutputBytes {
+		t.Fatalf("Expected output size limit error, got %v", err)
+	}
+}
+
+func TestConvertInputSizeLimit(t *testing.T) {
+	opts := getTestOptions()
+	opts.MaxFileSize = 4
+	converter, err := NewConverter(opts)
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+
+	var limitErr *LimitError
+	if _, err := converter.ConvertFromString(context.Background(), "# Too long"); !errors.As(err, &limitErr) || limitErr.Limit != LimitInputBytes {
+		t.Fatalf("Expected input size limit error, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package mdpdf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Raw Typst snippets embedded in the merged markdown. The markdown is rendered
+// by cmarker, which passes <!--raw-typst ...--> comments through as Typst code.
+const (
+	rawPageBreak = "<!--raw-typst #pagebreak(weak: true) -->"
+	rawOutline   = "<!--raw-typst #outline(title: %s) -->"
+)
+
+// rawOutlinePattern matches rawOutline with any title quoted by typstString
+var rawOutlinePattern = regexp.MustCompile(`^<!--raw-typst #outline\(title: "(?:[^"\\]|\\.)*"\) -->$`)
+
+// isMergeSnippet reports whether a raw-typst comment is one MergeMarkdown inserts
+func isMergeSnippet(comment string) bool {
+	return comment == rawPageBreak || rawOutlinePattern.MatchString(comment)
+}
+
+// Part is one markdown document of a merged conversion
+type Part struct {
+	// Title is used as the part heading when MergeOptions.PartHeadings is set
+	Title string `json:"title"`
+	// Markdown is the markdown content of the part
+	Markdown string `json:"markdownContent"`
+}
+
+// MergeOptions controls how parts are combined into one document
+type MergeOptions struct {
+	// PageBreaks starts every part on a new page
+	PageBreaks bool `json:"pageBreaks"`
+	// PartHeadings inserts each part's Title as a level 1 heading
+	PartHeadings bool `json:"partHeadings"`
+	// TableOfContents generates a table of contents before the first part
+	TableOfContents bool `json:"tableOfContents"`
+	// TOCTitle overrides the table of contents title (default: "Contents")
+	TOCTitle string `json:"tocTitle"`
+}
+
+// MergeMarkdown concatenates parts into a single markdown document that
+// compiles as one Typst document, so headings, numbering and the table of
+// contents span all parts.
+func MergeMarkdown(parts []Part, opts MergeOptions) string {
+	var b strings.Builder
+
+	if opts.TableOfContents {
+		title := opts.TOCTitle
+		if title == "" {
+			title = "Contents"
+		}
+		fmt.Fprintf(&b, rawOutline+"\n\n", typstString(title))
+	}
+
+	for i, part := range parts {
+		if (i > 0 || opts.TableOfContents) && opts.PageBreaks {
+			b.WriteString(rawPageBreak + "\n\n")
+		}
+		if opts.PartHeadings && part.Title != "" {
+			b.WriteString("# " + part.Title + "\n\n")
+		}
+		b.WriteString(strings.TrimSpace(part.Markdown))
+		b.WriteString("\n\n")
+	}
+
+	return b.String()
+}
+
+// ConvertMerged merges parts and converts them to a single PDF
+func (c *Converter) ConvertMerged(ctx context.Context, parts []Part, opts MergeOptions, convertOpts ...ConvertOption) ([]byte, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no documents to merge")
+	}
+	return c.ConvertFromString(ctx, MergeMarkdown(parts, opts), convertOpts...)
+}
+
+// ConvertFilesMerged reads markdown files in order and converts them to a single PDF.
+// Part titles are derived from the file names.
+func (c *Converter) ConvertFilesMerged(ctx context.Context, inputPaths []string, opts MergeOptions, convertOpts ...ConvertOption) ([]byte, error) {
+	parts, err := PartsFromFiles(inputPaths)
+	if err != nil {
+		return nil, err
+	}
+	return c.ConvertMerged(ctx, parts, opts, convertOpts...)
+}
+
+// PartsFromFiles reads markdown files into parts titled after their file names
+func PartsFromFiles(paths []string) ([]Part, error) {
+	parts := make([]Part, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input file: %w", err)
+		}
+		parts = append(parts, Part{Title: titleFromFilename(path), Markdown: string(content)})
+	}
+	return parts, nil
+}
+
+// titleFromFilename turns "section-2_algebra.md" into "section 2 algebra"
+func titleFromFilename(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' }), " ")
+}
+
+// typstString quotes s as a Typst string literal. ">" is escaped so the
+// literal cannot close the surrounding HTML comment.
+func typstString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ", ">", `\u{3e}`).Replace(s) + `"`
+}
@@ -0,0 +1,47 @@
+package mdpdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeMarkdown(t *testing.T) {
+	parts := []Part{
+		{Title: "Part A", Markdown: "Question one\n"},
+		{Title: "Part B", Markdown: "\nQuestion two"},
+	}
+
+	merged := MergeMarkdown(parts, MergeOptions{PageBreaks: true, PartHeadings: true, TableOfContents: true, TOCTitle: `Exam "Booklet"`})
+
+	expected := []string{
+		`<!--raw-typst #outline(title: "Exam \"Booklet\"") -->`,
+		rawPageBreak,
+		"# Part A",
+		"Question one",
+		rawPageBreak,
+		"# Part B",
+		"Question two",
+	}
+	rest := merged
+	for _, want := range expected {
+		i := strings.Index(rest, want)
+		if i < 0 {
+			t.Fatalf("Expected %q in order in merged markdown:\n%s", want, merged)
+		}
+		rest = rest[i+len(want):]
+	}
+}
+
+func TestMergeMarkdownPlain(t *testing.T) {
+	merged := MergeMarkdown([]Part{{Title: "A", Markdown: "one"}, {Title: "B", Markdown: "two"}}, MergeOptions{})
+
+	if merged != "one\n\ntwo\n\n" {
+		t.Fatalf("Expected plain concatenation, got %q", merged)
+	}
+}
+
+func TestTitleFromFilename(t *testing.T) {
+	if got := titleFromFilename("sections/02-algebra_basics.md"); got != "02 algebra basics" {
+		t.Fatalf("Unexpected title %q", got)
+	}
+}
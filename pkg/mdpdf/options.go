@@ -0,0 +1,180 @@
+package mdpdf
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Metadata is the document information written to the PDF
+type Metadata struct {
+	Title    string
+	Authors  []string
+	Keywords []string
+}
+
+// ConvertOption overrides the converter's Options for a single conversion
+type ConvertOption func(*convertSettings)
+
+// convertSettings are the effective settings of one conversion: the converter's
+// Options with the ConvertOptions of the call applied
+type convertSettings struct {
+	templatePath string
+	template     []byte
+	templateFS   fs.FS
+	timeout      time.Duration
+	timeoutSet   bool // WithTimeout applies even if the context has a deadline
+	variables    map[string]string
+	format       string
+	metadata     *Metadata
+	baseDir      string
+}
+
+// WithTimeout limits the conversion time. Unlike Options.Timeout it also applies
+// when the context has a deadline; the earlier of both wins.
+func WithTimeout(timeout time.Duration) ConvertOption {
+	return func(s *convertSettings) {
+		s.timeout = timeout
+		s.timeoutSet = true
+	}
+}
+
+// WithTemplate converts with another template file, read from Options.TemplateFS
+// if set. It replaces Options.Template; ConvertTypst ignores it.
+func WithTemplate(templatePath string) ConvertOption {
+	return func(s *convertSettings) {
+		s.templatePath = templatePath
+		s.template = nil
+	}
+}
+
+// WithVariables passes variables to the template, which reads them as strings
+// with sys.inputs.at("name", default: ""). They are added to Options.Variables,
+// replacing variables of the same name.
+func WithVariables(variables map[string]string) ConvertOption {
+	return func(s *convertSettings) {
+		merged := make(map[string]string, len(s.variables)+len(variables))
+		for name, value := range s.variables {
+			merged[name] = value
+		}
+		for name, value := range variables {
+			merged[name] = value
+		}
+		s.variables = merged
+	}
+}
+
+// WithFormat sets the page format, a Typst paper name such as "a4" or "us-letter".
+// Templates that set the paper themselves take precedence.
+func WithFormat(format string) ConvertOption {
+	return func(s *convertSettings) {
+		s.format = format
+	}
+}
+
+// WithMetadata sets the title, authors and keywords of the PDF
+func WithMetadata(metadata Metadata) ConvertOption {
+	return func(s *convertSettings) {
+		s.metadata = &metadata
+	}
+}
+
+// WithBaseDir sets the directory images, files read with read() and included
+// files resolve against; Typst denies access to files outside of it. Converting
+// a markdown file usually passes the file's directory.
+func WithBaseDir(dir string) ConvertOption {
+	return func(s *convertSettings) {
+		s.baseDir = dir
+	}
+}
+
+// settings layers the call's options over the converter's Options
+func (c *Converter) settings(opts []ConvertOption) *convertSettings {
+	s := &convertSettings{
+		templatePath: c.options.TemplatePath,
+		template:     c.options.Template,
+		templateFS:   c.options.TemplateFS,
+		timeout:      c.options.Timeout,
+		variables:    c.options.Variables,
+		format:       c.options.Format,
+		metadata:     c.options.Metadata,
+		baseDir:      c.options.BaseDir,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// context applies the timeout: Options.Timeout only when ctx has no deadline
+// of its own, WithTimeout always. Zero means no timeout.
+func (s *convertSettings) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok && !s.timeoutSet {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// root returns the compile root: the base directory, else the temp directory
+func (s *convertSettings) root() string {
+	if s.baseDir == "" {
+		return os.TempDir()
+	}
+	return s.baseDir
+}
+
+// prelude returns the Typst set rules placed before the document for the
+// format and metadata, or "" if neither is set
+func (s *convertSettings) prelude() string {
+	var b strings.Builder
+	if s.format != "" {
+		b.WriteString("#set page(paper: " + typstString(s.format) + ")\n")
+	}
+	if m := s.metadata; m != nil {
+		var fields []string
+		if m.Title != "" {
+			fields = append(fields, "title: "+typstString(m.Title))
+		}
+		if len(m.Authors) > 0 {
+			fields = append(fields, "author: "+typstArray(m.Authors))
+		}
+		if len(m.Keywords) > 0 {
+			fields = append(fields, "keywords: "+typstArray(m.Keywords))
+		}
+		if len(fields) > 0 {
+			b.WriteString("#set document(" + strings.Join(fields, ", ") + ")\n")
+		}
+	}
+	return b.String()
+}
+
+// compileArgs returns the Typst command line arguments passing the variables
+func (s *convertSettings) compileArgs() []string {
+	names := make([]string, 0, len(s.variables))
+	for name := range s.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		args = append(args, "--input", name+"="+s.variables[name])
+	}
+	return args
+}
+
+// typstArray quotes values as a Typst array of strings
+func typstArray(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = typstString(v)
+	}
+	// The trailing comma keeps a single value an array
+	return "(" + strings.Join(quoted, ", ") + ",)"
+}
@@ -0,0 +1,111 @@
+package mdpdf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConvertOptionsLayering(t *testing.T) {
+	opts := getTestOptions()
+	opts.Variables = map[string]string{"class": "10b", "subject": "Physics"}
+	opts.Format = "a4"
+	converter, err := NewConverter(opts)
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+
+	// Later changes to the options must not affect the converter
+	opts.Variables["class"] = "changed"
+
+	s := converter.settings([]ConvertOption{
+		WithTemplate("other.typ"),
+		WithVariables(map[string]string{"subject": "Chemistry"}),
+		WithMetadata(Metadata{Title: `Exam "A"`, Authors: []string{"Ada"}}),
+	})
+	if s.templatePath != "other.typ" || s.format != "a4" {
+		t.Fatalf("Unexpected settings: %+v", s)
+	}
+	if want := []string{"--input", "class=10b", "--input", "subject=Chemistry"}; !reflect.DeepEqual(s.compileArgs(), want) {
+		t.Fatalf("Expected %v, got %v", want, s.compileArgs())
+	}
+	want := "#set page(paper: \"a4\")\n#set document(title: \"Exam \\\"A\\\"\", author: (\"Ada\",))\n"
+	if got := s.prelude(); got != want {
+		t.Fatalf("Expected prelude %q, got %q", want, got)
+	}
+
+	if defaults := converter.settings(nil); defaults.templatePath != opts.TemplatePath || defaults.prelude() != "#set page(paper: \"a4\")\n" {
+		t.Fatalf("Unexpected default settings: %+v", defaults)
+	}
+}
+
+func TestConvertSettingsTimeout(t *testing.T) {
+	s := &convertSettings{timeout: time.Minute}
+
+	// Options.Timeout applies without a deadline...
+	ctx, cancel := s.context(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatal("Expected Options.Timeout to set a deadline")
+	}
+
+	// ...but not over the caller's own deadline
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelParent()
+	ctx, cancel = s.context(parent)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) < 50*time.Minute {
+		t.Fatal("Expected the caller's deadline to be kept")
+	}
+
+	// WithTimeout always applies
+	WithTimeout(time.Minute)(s)
+	ctx, cancel = s.context(parent)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) > 2*time.Minute {
+		t.Fatal("Expected WithTimeout to shorten the deadline")
+	}
+}
+
+func TestConverterConcurrentUse(t *testing.T) {
+	template := filepath.Join(t.TempDir(), "plain.typ")
+	if err := os.WriteFile(template, []byte("#sys.inputs.at(\"name\", default: \"\")\n\n{{Placeholder Markdown}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.TemplatePath = template
+	converter, err := NewConverter(opts)
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			title := fmt.Sprintf("Document %d", i)
+			pdf, err := converter.ConvertFromString(context.Background(), "Hello",
+				WithMetadata(Metadata{Title: title}),
+				WithVariables(map[string]string{"name": title}))
+			if err == nil && !strings.Contains(string(pdf), "/Title ("+title+")") {
+				err = fmt.Errorf("PDF lacks the title %q", title)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Conversion %d failed: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package mdpdf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultFileMode is the permission of written PDF files when none is set
+const DefaultFileMode os.FileMode = 0644
+
+// ErrOutputExists is returned when NoOverwrite is set and the output file exists
+var ErrOutputExists = errors.New("output file already exists")
+
+// WriteFile writes data to path atomically: it writes a temporary file in the
+// same directory and renames it to path, so a crash never leaves a truncated
+// file behind. perm 0 means DefaultFileMode. With noOverwrite an existing file
+// is kept and an error wrapping ErrOutputExists is returned.
+func WriteFile(path string, data []byte, perm os.FileMode, noOverwrite bool) error {
+	if perm == 0 {
+		perm = DefaultFileMode
+	}
+	if noOverwrite {
+		if err := checkNotExists(path); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once renamed or linked
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if !noOverwrite {
+		return os.Rename(tmpPath, path)
+	}
+
+	// A hard link fails if path appeared meanwhile, unlike a rename. File
+	// systems without hard links fall back to the check above.
+	err = os.Link(tmpPath, path)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, os.ErrExist):
+		return fmt.Errorf("%w: %s", ErrOutputExists, path)
+	default:
+		return os.Rename(tmpPath, path)
+	}
+}
+
+// checkNotExists returns an error wrapping ErrOutputExists if path exists
+func checkNotExists(path string) error {
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%w: %s", ErrOutputExists, path)
+	}
+	return nil
+}
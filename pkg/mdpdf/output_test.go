@@ -0,0 +1,50 @@
+package mdpdf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.pdf")
+
+	if err := WriteFile(path, []byte("first"), 0600, false); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	if err := WriteFile(path, []byte("second"), 0, true); !errors.Is(err, ErrOutputExists) {
+		t.Fatalf("Expected ErrOutputExists, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "first" {
+		t.Fatalf("Expected the existing file to be kept, got %q", data)
+	}
+
+	if err := WriteFile(path, []byte("second"), 0, false); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "second" {
+		t.Fatalf("Expected the file to be replaced, got %q", data)
+	}
+
+	if err := WriteFile(filepath.Join(dir, "new.pdf"), []byte("new"), 0, true); err != nil {
+		t.Fatalf("WriteFile of a new file failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected no temporary files to be left, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,122 @@
+package mdpdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Raw Typst modes of a RawTypstPolicy
+const (
+	RawTypstAllowed       = "allowed"       // anyone may submit Typst source
+	RawTypstDisabled      = "disabled"      // only markdown is converted
+	RawTypstAuthenticated = "authenticated" // only authenticated callers may submit Typst source
+)
+
+// ErrRawTypstNotAllowed is wrapped by every error returned for Typst source rejected by a RawTypstPolicy
+var ErrRawTypstNotAllowed = errors.New("raw Typst input not allowed")
+
+var (
+	// packageSpecPattern matches string literals naming a Typst package, e.g. "@preview/cmarker:0.1.1"
+	packageSpecPattern = regexp.MustCompile(`"(@[A-Za-z0-9_-]+/[A-Za-z0-9_-]+)(?::[^"]*)?"`)
+	// importPattern matches import statements and captures the first character of the imported expression
+	importPattern = regexp.MustCompile(`(?:#|[{;]\s*)import\b\s*(\S?)`)
+	// rawTypstBlockPattern matches the <!--raw-typst ...--> comments cmarker passes
+	// through as Typst code and captures the code
+	rawTypstBlockPattern = regexp.MustCompile(`(?s)<!--\s*raw-typst(.*?)-->`)
+)
+
+// RawTypstPolicy decides whether user-supplied Typst source may be compiled.
+// Markdown can carry Typst code as well: templates render it with cmarker, which
+// compiles <!--raw-typst ...--> comments as Typst, so CheckMarkdown applies the
+// same policy to those comments.
+type RawTypstPolicy struct {
+	// Mode is RawTypstAllowed, RawTypstDisabled or RawTypstAuthenticated ("" = allowed)
+	Mode string
+	// AllowedPackages restricts package imports to these packages, given as
+	// "@namespace/name" (any version) or "@namespace/name:version". Empty allows all packages.
+	AllowedPackages []string
+}
+
+// Validate reports an unknown mode
+func (p RawTypstPolicy) Validate() error {
+	switch p.Mode {
+	case "", RawTypstAllowed, RawTypstDisabled, RawTypstAuthenticated:
+		return nil
+	default:
+		return fmt.Errorf("unknown raw Typst mode %q", p.Mode)
+	}
+}
+
+// Check returns an error wrapping ErrRawTypstNotAllowed if typstContent may not be
+// compiled. The package allowlist is a static check: imports of computed
+// strings are rejected when it is set.
+func (p RawTypstPolicy) Check(typstContent string, authenticated bool) error {
+	switch p.Mode {
+	case RawTypstDisabled:
+		return fmt.Errorf("%w: raw Typst input is disabled", ErrRawTypstNotAllowed)
+	case RawTypstAuthenticated:
+		if !authenticated {
+			return fmt.Errorf("%w: raw Typst input requires authentication", ErrRawTypstNotAllowed)
+		}
+	}
+
+	if len(p.AllowedPackages) == 0 {
+		return nil
+	}
+
+	for _, match := range importPattern.FindAllStringSubmatch(typstContent, -1) {
+		if match[1] != `"` {
+			return fmt.Errorf("%w: imports must name a string literal", ErrRawTypstNotAllowed)
+		}
+	}
+	for _, match := range packageSpecPattern.FindAllStringSubmatch(typstContent, -1) {
+		spec := strings.Trim(match[0], `"`)
+		if !p.allowsPackage(match[1], spec) {
+			return fmt.Errorf("%w: package %s is not on the allowlist", ErrRawTypstNotAllowed, spec)
+		}
+	}
+	return nil
+}
+
+// CheckMarkdown applies the policy to the Typst code embedded in markdown as
+// <!--raw-typst ...--> comments. Markdown without such comments always passes,
+// as do the page breaks and outline inserted by MergeMarkdown.
+func (p RawTypstPolicy) CheckMarkdown(markdown string, authenticated bool) error {
+	var code []string
+	for _, match := range rawTypstBlockPattern.FindAllStringSubmatch(markdown, -1) {
+		if !isMergeSnippet(match[0]) {
+			code = append(code, match[1])
+		}
+	}
+	if len(code) == 0 {
+		return nil
+	}
+	return p.Check(strings.Join(code, "\n"), authenticated)
+}
+
+// allowsPackage reports whether the package name (or its exact versioned spec) is allowlisted
+func (p RawTypstPolicy) allowsPackage(name, spec string) bool {
+	for _, allowed := range p.AllowedPackages {
+		if allowed == name || allowed == spec {
+			return true
+		}
+	}
+	return false
+}
+
+type authenticatedKey struct{}
+
+// WithAuthenticatedCaller marks ctx as coming from an authenticated caller for
+// RawTypstAuthenticated policies
+func WithAuthenticatedCaller(ctx context.Context) context.Context {
+	return context.WithValue(ctx, authenticatedKey{}, true)
+}
+
+// IsAuthenticatedCaller reports whether ctx was marked by WithAuthenticatedCaller
+func IsAuthenticatedCaller(ctx context.Context) bool {
+	authenticated, _ := ctx.Value(authenticatedKey{}).(bool)
+	return authenticated
+}
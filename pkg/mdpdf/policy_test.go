@@ -0,0 +1,94 @@
+package mdpdf
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRawTypstPolicy(t *testing.T) {
+	allowlist := RawTypstPolicy{AllowedPackages: []string{"@preview/cmarker", "@preview/cetz:0.3.1"}}
+
+	tests := []struct {
+		name          string
+		policy        RawTypstPolicy
+		source        string
+		authenticated bool
+		allowed       bool
+	}{
+		{"allowed by default", RawTypstPolicy{}, "= Hello", false, true},
+		{"disabled", RawTypstPolicy{Mode: RawTypstDisabled}, "= Hello", true, false},
+		{"authenticated caller", RawTypstPolicy{Mode: RawTypstAuthenticated}, "= Hello", true, true},
+		{"anonymous caller", RawTypstPolicy{Mode: RawTypstAuthenticated}, "= Hello", false, false},
+		{"allowlisted package", allowlist, `#import "@preview/cmarker:0.1.1"`, false, true},
+		{"allowlisted version", allowlist, `#import "@preview/cetz:0.3.1": canvas`, false, true},
+		{"other version", allowlist, `#import "@preview/cetz:0.2.0"`, false, false},
+		{"unlisted package", allowlist, `#import "@preview/tablex:0.0.8"`, false, false},
+		{"local import", allowlist, `#import "utils.typ": helper`, false, true},
+		{"computed import", allowlist, `#import ("@preview/" + "tablex:0.0.8")`, false, false},
+		{"import in code block", allowlist, `#{ import "@local/secret:1.0.0" }`, false, false},
+		{"prose mentioning import", allowlist, "We import data from files.", false, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.policy.Check(tt.source, tt.authenticated)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", tt.name, tt.allowed, err)
+		}
+		if err != nil && !errors.Is(err, ErrRawTypstNotAllowed) {
+			t.Errorf("%s: error does not wrap ErrRawTypstNotAllowed: %v", tt.name, err)
+		}
+	}
+
+	if err := (RawTypstPolicy{Mode: "sometimes"}).Validate(); err == nil {
+		t.Error("Expected unknown mode to be rejected")
+	}
+}
+
+func TestRawTypstPolicyMarkdown(t *testing.T) {
+	disabled := RawTypstPolicy{Mode: RawTypstDisabled}
+	allowlist := RawTypstPolicy{AllowedPackages: []string{"@preview/cetz"}}
+	merged := MergeMarkdown([]Part{{Title: `A "quoted" --> title`, Markdown: "One"}, {Markdown: "Two"}},
+		MergeOptions{PageBreaks: true, TableOfContents: true, TOCTitle: `Contents" ) #import "@x/y:1" //`})
+
+	tests := []struct {
+		name     string
+		policy   RawTypstPolicy
+		markdown string
+		allowed  bool
+	}{
+		{"plain markdown", disabled, "# Hello\n\nSome *text*.", true},
+		{"embedded import", disabled, `<!--raw-typst #import "@preview/x:1.0.0": * -->`, false},
+		{"embedded code", disabled, "Text\n<!-- raw-typst\n#read(\"/etc/passwd\")\n-->", false},
+		{"merge snippets", disabled, merged, true},
+		{"unlisted package", allowlist, `<!--raw-typst #import "@preview/x:1.0.0": * -->`, false},
+		{"listed package", allowlist, `<!--raw-typst #import "@preview/cetz:0.3.1": canvas -->`, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.policy.CheckMarkdown(tt.markdown, false)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", tt.name, tt.allowed, err)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.RawTypst = disabled
+	converter, err := NewConverter(opts)
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+	_, err = converter.ConvertFromString(context.Background(), `<!--raw-typst #import "@preview/x:1.0.0": * -->`)
+	if !errors.Is(err, ErrRawTypstNotAllowed) {
+		t.Fatalf("Expected ConvertFromString to reject embedded Typst, got %v", err)
+	}
+
+	// Callers compiling the source themselves get the same checks
+	_, err = converter.RenderMarkdown(context.Background(), `<!--raw-typst #import "@preview/x:1.0.0": * -->`)
+	if !errors.Is(err, ErrRawTypstNotAllowed) {
+		t.Fatalf("Expected RenderMarkdown to reject embedded Typst, got %v", err)
+	}
+	if err := converter.CheckTypst(context.Background(), "= Hello"); !errors.Is(err, ErrRawTypstNotAllowed) {
+		t.Fatalf("Expected CheckTypst to reject Typst source, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package mdpdf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ConvertResult is a generated PDF with information about its conversion
+type ConvertResult struct {
+	PDF             []byte
+	Pages           int
+	CompileDuration time.Duration
+	// TemplateName is the template file name without extension, empty for ConvertTypst
+	TemplateName string
+	// TemplateHash is the hex SHA-256 of the template content, empty for ConvertTypst
+	TemplateHash string
+	// Warnings are the warnings Typst reported while compiling
+	Warnings []Diagnostic
+	// CacheHit is set by callers that cache results, such as the HTTP service.
+	// The Converter itself does not cache.
+	CacheHit bool
+}
+
+// TemplateName names a template after its file, e.g. "exam-template" for "templates/exam-template.typ"
+func TemplateName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// TemplateHash identifies a template version by its content
+func TemplateHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Warnings returns the warnings in Typst compiler output
+func Warnings(output string) []Diagnostic {
+	var warnings []Diagnostic
+	for _, d := range ParseDiagnostics(output) {
+		if d.Severity == "warning" {
+			warnings = append(warnings, d)
+		}
+	}
+	return warnings
+}
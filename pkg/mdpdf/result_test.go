@@ -0,0 +1,41 @@
+package mdpdf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertFromStringResult(t *testing.T) {
+	template := []byte("#set text(font: \"No Such Font\")\n{{Placeholder Markdown}}\n")
+	templatePath := filepath.Join(t.TempDir(), "plain.typ")
+	if err := os.WriteFile(templatePath, template, 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.TemplatePath = templatePath
+	converter, err := NewConverter(opts)
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+
+	res, err := converter.ConvertFromStringResult(context.Background(), "One\n#pagebreak()\nTwo")
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	if len(res.PDF) == 0 || res.Pages != 2 || res.CompileDuration <= 0 {
+		t.Fatalf("Unexpected result: %d bytes, %d pages, %v", len(res.PDF), res.Pages, res.CompileDuration)
+	}
+	if res.TemplateName != "plain" || res.TemplateHash != TemplateHash(template) {
+		t.Fatalf("Unexpected template %q with hash %q", res.TemplateName, res.TemplateHash)
+	}
+	if len(res.Warnings) == 0 || !strings.Contains(res.Warnings[0].Message, "unknown font family") {
+		t.Fatalf("Expected an unknown font warning, got %+v", res.Warnings)
+	}
+	if res.CacheHit {
+		t.Fatal("Converter results are never cache hits")
+	}
+}
@@ -0,0 +1,179 @@
+package mdpdf
+
+import (
+	_ "embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultTemplateName is the name of the embedded default template
+const DefaultTemplateName = "exam-template"
+
+// markdownPlaceholder marks where templates take the markdown
+const markdownPlaceholder = "{{Placeholder Markdown}}"
+
+var (
+	// templateNamePattern restricts template names so they can never escape the template directory
+	templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// placeholderLiteralPattern matches the placeholder as the content of a Typst
+	// raw literal (`...`, ```...```) or string literal ("...")
+	placeholderLiteralPattern = regexp.MustCompile("(`+|\")\\s*" + regexp.QuoteMeta(markdownPlaceholder) + "\\s*(`+|\")")
+	// backtickRunPattern matches runs of backticks
+	backtickRunPattern = regexp.MustCompile("`+")
+	// typstStringEscaper escapes text for a Typst string literal
+	typstStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+)
+
+//go:embed exam-template.typ
+var defaultTemplate []byte
+
+// DefaultTemplate returns the content of the embedded default template
+func DefaultTemplate() []byte {
+	return append([]byte(nil), defaultTemplate...)
+}
+
+// TemplateFile is a template in a template directory
+type TemplateFile struct {
+	// Name is the file name without extension, see TemplateName
+	Name string
+	// Path is the path of the file, or "" for the embedded default template
+	Path string
+}
+
+// ValidTemplateName reports whether name may name a template of a template
+// directory: only letters, digits, '_' and '-', so it cannot escape the directory
+func ValidTemplateName(name string) bool {
+	return templateNamePattern.MatchString(name)
+}
+
+// ListTemplates returns the default template, named after defaultPath or
+// DefaultTemplateName if defaultPath is empty, followed by the .typ files of dir
+// with valid names, sorted by name
+func ListTemplates(defaultPath, dir string) []TemplateFile {
+	templates := []TemplateFile{{DefaultTemplateName, ""}}
+	if defaultPath != "" {
+		templates[0] = TemplateFile{TemplateName(defaultPath), defaultPath}
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.typ"))
+	for _, match := range matches {
+		if name := TemplateName(match); name != templates[0].Name && ValidTemplateName(name) {
+			templates = append(templates, TemplateFile{name, match})
+		}
+	}
+
+	others := templates[1:]
+	sort.Slice(others, func(i, j int) bool { return others[i].Name < others[j].Name })
+	return templates
+}
+
+// FindTemplate returns the path of the template named name in dir
+func FindTemplate(dir, name string) (string, error) {
+	if !ValidTemplateName(name) {
+		return "", fmt.Errorf("invalid template name %q", name)
+	}
+
+	path := filepath.Join(dir, name+".typ")
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("unknown template %q", name)
+	}
+	return path, nil
+}
+
+// ReadTemplate reads a template file, or the embedded default template if path is empty
+func ReadTemplate(path string) ([]byte, error) {
+	if path == "" {
+		return DefaultTemplate(), nil
+	}
+	return os.ReadFile(path)
+}
+
+// loadTemplate returns the name and content of the template of a conversion:
+// the template bytes if set, else the file at templatePath in templateFS or on
+// disk, else the embedded default template
+func (s *convertSettings) loadTemplate() (string, []byte, error) {
+	switch {
+	case s.template != nil:
+		if s.templatePath == "" {
+			return "", s.template, nil
+		}
+		return TemplateName(s.templatePath), s.template, nil
+	case s.templatePath == "":
+		return DefaultTemplateName, defaultTemplate, nil
+	}
+
+	var content []byte
+	var err error
+	if s.templateFS != nil {
+		content, err = fs.ReadFile(s.templateFS, s.templatePath)
+	} else {
+		content, err = os.ReadFile(s.templatePath)
+	}
+	if err != nil {
+		return "", nil, &TemplateError{fmt.Errorf("failed to read template: %w", err)}
+	}
+	return TemplateName(s.templatePath), content, nil
+}
+
+// checkTemplateFile returns a *TemplateError if the template file of opts does not exist
+func checkTemplateFile(opts *Options) error {
+	if opts.Template != nil || opts.TemplatePath == "" {
+		return nil
+	}
+
+	var err error
+	if opts.TemplateFS != nil {
+		_, err = fs.Stat(opts.TemplateFS, opts.TemplatePath)
+	} else {
+		_, err = os.Stat(opts.TemplatePath)
+	}
+	if err != nil {
+		return &TemplateError{fmt.Errorf("template file not found: %w", err)}
+	}
+	return nil
+}
+
+// renderTemplate substitutes markdown for the placeholder of a template. Markdown
+// is data: a placeholder in a raw or string literal is replaced along with the
+// literal by one that holds the markdown whatever it contains, so backticks or
+// quotes in the markdown cannot end the literal and run as Typst code. Only a
+// placeholder outside of literals is replaced by the markdown as is, which makes
+// the markdown Typst source; markdownIsTypst reports that case.
+func renderTemplate(template, markdown string) (typstContent string, markdownIsTypst bool, err error) {
+	index := strings.Index(template, markdownPlaceholder)
+	if index < 0 {
+		return "", false, &TemplateError{fmt.Errorf("template must contain %s placeholder", markdownPlaceholder)}
+	}
+
+	loc := placeholderLiteralPattern.FindStringSubmatchIndex(template)
+	if loc == nil || loc[0] > index || template[loc[2]:loc[3]] != template[loc[4]:loc[5]] {
+		return template[:index] + markdown + template[index+len(markdownPlaceholder):], true, nil
+	}
+
+	var literal string
+	if template[loc[2]] == '"' {
+		literal = `"` + typstStringEscaper.Replace(markdown) + `"`
+	} else {
+		literal = rawLiteral(markdown)
+	}
+	return template[:loc[0]] + literal + template[loc[1]:], false, nil
+}
+
+// rawLiteral returns a Typst raw block holding text. Its fence is longer than
+// any backtick run in text and at least three backticks long, so the text
+// starts on its own line without a language tag and keeps its indentation.
+func rawLiteral(text string) string {
+	fence := 3
+	for _, run := range backtickRunPattern.FindAllString(text, -1) {
+		if len(run) >= fence {
+			fence = len(run) + 1
+		}
+	}
+	delimiter := strings.Repeat("`", fence)
+	return delimiter + "\n" + text + "\n" + delimiter
+}
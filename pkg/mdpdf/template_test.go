@@ -0,0 +1,154 @@
+package mdpdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestDefaultTemplate(t *testing.T) {
+	converter, err := NewConverter(DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+
+	content, err := converter.GetTemplateContent()
+	if err != nil {
+		t.Fatalf("Failed to read template: %v", err)
+	}
+	if content != string(DefaultTemplate()) || !bytes.Contains(DefaultTemplate(), []byte("{{Placeholder Markdown}}")) {
+		t.Fatal("Converter without a template path does not use the embedded template")
+	}
+}
+
+func TestTemplateSources(t *testing.T) {
+	plain := []byte("{{Placeholder Markdown}}")
+	fsys := fstest.MapFS{"templates/plain.typ": {Data: plain}}
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"bytes", Options{Template: plain}, ""},
+		{"named bytes", Options{Template: plain, TemplatePath: "letter.typ"}, "letter"},
+		{"file system", Options{TemplateFS: fsys, TemplatePath: "templates/plain.typ"}, "plain"},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.Template, opts.TemplatePath, opts.TemplateFS = tt.opts.Template, tt.opts.TemplatePath, tt.opts.TemplateFS
+		converter, err := NewConverter(opts)
+		if err != nil {
+			t.Fatalf("%s: failed to create converter: %v", tt.name, err)
+		}
+
+		res, err := converter.ConvertFromStringResult(context.Background(), "= Hello")
+		if err != nil {
+			t.Fatalf("%s: conversion failed: %v", tt.name, err)
+		}
+		if res.TemplateName != tt.want || res.TemplateHash != TemplateHash(plain) {
+			t.Errorf("%s: got template %q (%s)", tt.name, res.TemplateName, res.TemplateHash)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.TemplateFS = fsys
+	opts.TemplatePath = "plain.typ"
+	var templateErr *TemplateError
+	if _, err := NewConverter(opts); !errors.As(err, &templateErr) {
+		t.Fatalf("Expected *TemplateError for a template missing from the file system, got %v", err)
+	}
+}
+
+func TestValidateTemplateTimeout(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Template = []byte("#let n = 0\n#for i in range(100000000) { n += i }\n#let markdown = \"{{Placeholder Markdown}}\"")
+	opts.Timeout = 200 * time.Millisecond
+	converter, err := NewConverter(opts)
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+
+	if err := converter.ValidateTemplate(); err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded, got: %v", err)
+	}
+}
+
+func TestMarkdownCannotEscapeTemplateLiterals(t *testing.T) {
+	// Stand-ins for cmarker.render, which takes the markdown as raw content or a string
+	templates := map[string]string{
+		"raw":    "#let render(md) = md.text\n#render(`\n{{Placeholder Markdown}}\n`)",
+		"string": "#let render(md) = md\n#render(\"{{Placeholder Markdown}}\")",
+	}
+	documents := []string{
+		"Some `code`\n`\n#panic(\"injected\")\n`",
+		"```\n#import \"@preview/cetz:0.3.1\": canvas\n```\n````\n#panic(\"injected\")\n````",
+		"\"\n#panic(\"injected\")\n\" \\\"",
+		"    indented code ``` with a fence",
+	}
+
+	for name, template := range templates {
+		opts := DefaultOptions()
+		opts.Template = []byte(template)
+		opts.RawTypst = RawTypstPolicy{Mode: RawTypstDisabled}
+		converter, err := NewConverter(opts)
+		if err != nil {
+			t.Fatalf("Failed to create converter: %v", err)
+		}
+		for _, markdown := range documents {
+			if _, err := converter.ConvertFromString(context.Background(), markdown); err != nil {
+				t.Errorf("%s template: markdown %q ran as Typst: %v", name, markdown, err)
+			}
+		}
+	}
+
+	// Text in a raw block keeps its backticks and indentation
+	if got := rawLiteral("    a ``` b"); got != "````\n    a ``` b\n````" {
+		t.Errorf("Unexpected raw literal %q", got)
+	}
+
+	// A template that compiles the markdown as Typst is subject to the policy
+	opts := DefaultOptions()
+	opts.Template = []byte("{{Placeholder Markdown}}")
+	opts.RawTypst = RawTypstPolicy{Mode: RawTypstDisabled}
+	converter, err := NewConverter(opts)
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+	if _, err := converter.ConvertFromString(context.Background(), "= Hello"); !errors.Is(err, ErrRawTypstNotAllowed) {
+		t.Fatalf("Expected ErrRawTypstNotAllowed for a template without a literal, got %v", err)
+	}
+}
+
+func TestListTemplates(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.typ", "a.typ", "a-b.typ", "bad name.typ", "notes.txt", DefaultTemplateName + ".typ"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{{Placeholder Markdown}}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var names []string
+	for _, template := range ListTemplates("", dir) {
+		names = append(names, template.Name)
+	}
+	if want := []string{DefaultTemplateName, "a", "a-b", "b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected templates %v, got %v", want, names)
+	}
+
+	if path, err := FindTemplate(dir, "a-b"); err != nil || path != filepath.Join(dir, "a-b.typ") {
+		t.Errorf("Expected a-b.typ, got %q, %v", path, err)
+	}
+	for _, name := range []string{"missing", "bad name", "../a", ""} {
+		if _, err := FindTemplate(dir, name); err == nil {
+			t.Errorf("Expected an error for template name %q", name)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package mdpdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	// gotypst installs the Typst compiler and its fonts when it is initialized
+	_ "github.com/francescoalemanno/gotypst"
+)
+
+// TypstBinaryEnv names the environment variable that overrides the Typst compiler
+const TypstBinaryEnv = "MDPDF_TYPST_BINARY"
+
+// ErrTypstNotFound is wrapped by the error returned when the Typst compiler is missing
+var ErrTypstNotFound = errors.New("typst compiler not found")
+
+// gotypstDir returns the directory gotypst installs the compiler and fonts into.
+// gotypst does not export it; the layout is that of the gotypst version in go.mod,
+// which TestTypstBinary verifies.
+func gotypstDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	return filepath.Join(cacheDir, "gotypst")
+}
+
+// FontDir returns the directory gotypst installs its bundled fonts into
+func FontDir() string {
+	return filepath.Join(gotypstDir(), "fonts")
+}
+
+// TypstBinary returns the path of the Typst compiler: $MDPDF_TYPST_BINARY if
+// set, else the compiler gotypst installs. It returns an error wrapping
+// ErrTypstNotFound if there is no file at that path.
+func TypstBinary() (string, error) {
+	path := os.Getenv(TypstBinaryEnv)
+	if path == "" {
+		path = filepath.Join(gotypstDir(), runtime.GOARCH+"-"+runtime.GOOS)
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", fmt.Errorf("%w at %s", ErrTypstNotFound, path)
+	}
+	return path, nil
+}
+
+// RunTypst compiles Typst source with root as the project root: relative paths
+// in the source resolve against it, and Typst denies access to files outside of
+// it. args are passed to typst compile, e.g. "--input", "name=value". The result
+// holds the PDF and the warnings; a failed compilation returns a *CompileError.
+// When ctx is done the compiler is killed and ctx.Err() returned. The source and
+// the PDF are piped, so even a killed compile leaves no files behind.
+func RunTypst(ctx context.Context, typstContent, root string, args []string) (*ConvertResult, error) {
+	binary, err := TypstBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	cmdArgs := append([]string{"compile", "--root", root, "--font-path", FontDir()}, args...)
+	cmd := exec.CommandContext(ctx, binary, append(cmdArgs, "-", "-")...)
+	cmd.Stdin = strings.NewReader(typstContent)
+	var pdf, diagnostics bytes.Buffer
+	cmd.Stdout = &pdf
+	cmd.Stderr = &diagnostics
+	// Stop waiting for output pipes once the killed compiler is gone
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, newCompileError(fmt.Errorf("%v %v", diagnostics.String(), err))
+	}
+
+	return &ConvertResult{PDF: pdf.Bytes(), Warnings: Warnings(diagnostics.String())}, nil
+}
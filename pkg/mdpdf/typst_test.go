@@ -0,0 +1,30 @@
+package mdpdf
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTypstBinary(t *testing.T) {
+	// Fails when a gotypst upgrade installs the compiler elsewhere
+	binary, err := TypstBinary()
+	if err != nil {
+		t.Fatalf("Typst compiler not found: %v", err)
+	}
+	out, err := exec.Command(binary, "--version").CombinedOutput()
+	if err != nil || !strings.HasPrefix(string(out), "typst ") {
+		t.Fatalf("%s --version failed: %v %s", binary, err, out)
+	}
+
+	t.Setenv(TypstBinaryEnv, filepath.Join(t.TempDir(), "typst"))
+	if _, err := TypstBinary(); !errors.Is(err, ErrTypstNotFound) {
+		t.Fatalf("Expected ErrTypstNotFound for a missing override, got %v", err)
+	}
+	if _, err := QuickConvert(context.Background(), "# Test"); !errors.Is(err, ErrTypstNotFound) {
+		t.Fatalf("Expected conversions to fail with ErrTypstNotFound, got %v", err)
+	}
+}
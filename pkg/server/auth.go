@@ -0,0 +1,305 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyContextKey is the gin context key holding the authenticated *keyState
+const apiKeyContextKey = "apiKey"
+
+// APIKey describes a client key. Only the SHA-256 hash of the key is stored.
+type APIKey struct {
+	Name             string   `json:"name"`
+	Hash             string   `json:"hash"`
+	Admin            bool     `json:"admin"`
+	RateLimit        int      `json:"rateLimit"`        // requests per minute, 0 = unlimited
+	Burst            int      `json:"burst"`            // bucket size, defaults to RateLimit
+	DailyConversions int      `json:"dailyConversions"` // 0 = unlimited
+	DailyBytes       int64    `json:"dailyBytes"`       // PDF bytes per day, 0 = unlimited
+	Templates        []string `json:"templates"`        // allowed templates, empty = all
+}
+
+// apiKeyFile is the on-disk format of API_KEYS_FILE
+type apiKeyFile struct {
+	Keys []*APIKey `json:"keys"`
+}
+
+// KeyUsage holds the usage counters of a single key
+type KeyUsage struct {
+	Name             string `json:"name"`
+	Requests         int64  `json:"requests"`
+	Conversions      int64  `json:"conversions"`
+	Bytes            int64  `json:"bytes"`
+	RateLimited      int64  `json:"rateLimited"`
+	QuotaExceeded    int64  `json:"quotaExceeded"`
+	TodayConversions int    `json:"todayConversions"`
+	TodayBytes       int64  `json:"todayBytes"`
+	LastUsed         string `json:"lastUsed,omitempty"`
+}
+
+// keyState tracks rate limiting, quotas and usage for one key
+type keyState struct {
+	key *APIKey
+
+	mu         sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+	day        string
+	usage      KeyUsage
+}
+
+// KeyStore holds the configured API keys indexed by hash
+type KeyStore struct {
+	keys map[string]*keyState
+}
+
+// HashAPIKey returns the at-rest representation of a plaintext key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadKeyStore loads keys from the API_KEYS_FILE and API_KEYS settings.
+// API_KEYS is a comma separated list of name=sha256hex pairs using the default limits.
+func LoadKeyStore(config *Config) (*KeyStore, error) {
+	var keys []*APIKey
+
+	if config.APIKeysFile != "" {
+		data, err := os.ReadFile(config.APIKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API keys file: %w", err)
+		}
+
+		var file apiKeyFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse API keys file: %w", err)
+		}
+		keys = append(keys, file.Keys...)
+	}
+
+	if config.APIKeys != "" {
+		for _, entry := range strings.Split(config.APIKeys, ",") {
+			name, hash, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid API_KEYS entry %q (expected name=sha256hex)", entry)
+			}
+			keys = append(keys, &APIKey{
+				Name:             name,
+				Hash:             hash,
+				RateLimit:        config.DefaultRateLimit,
+				DailyConversions: config.DefaultDailyConversions,
+				DailyBytes:       config.DefaultDailyBytes,
+			})
+		}
+	}
+
+	store := &KeyStore{keys: make(map[string]*keyState, len(keys))}
+	for _, key := range keys {
+		hash := strings.ToLower(strings.TrimPrefix(key.Hash, "sha256:"))
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("API key %q: hash must be a hex encoded SHA-256 digest", key.Name)
+		}
+		if key.Name == "" {
+			return nil, fmt.Errorf("API key with hash %s…: missing name", hash[:8])
+		}
+		if _, exists := store.keys[hash]; exists {
+			return nil, fmt.Errorf("API key %q: duplicate hash", key.Name)
+		}
+		if key.Burst <= 0 {
+			key.Burst = key.RateLimit
+		}
+
+		store.keys[hash] = &keyState{
+			key:    key,
+			tokens: float64(key.Burst),
+			usage:  KeyUsage{Name: key.Name},
+		}
+	}
+
+	return store, nil
+}
+
+// Enabled reports whether authentication is required
+func (ks *KeyStore) Enabled() bool {
+	return ks != nil && len(ks.keys) > 0
+}
+
+// Lookup returns the state of a plaintext key, or nil if it is unknown
+func (ks *KeyStore) Lookup(key string) *keyState {
+	return ks.keys[HashAPIKey(key)]
+}
+
+// Usage returns a snapshot of all key usage counters sorted by name
+func (ks *KeyStore) Usage() []KeyUsage {
+	usage := make([]KeyUsage, 0, len(ks.keys))
+	for _, st := range ks.keys {
+		st.mu.Lock()
+		st.rollover(time.Now())
+		usage = append(usage, st.usage)
+		st.mu.Unlock()
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+	return usage
+}
+
+// rollover resets the daily counters when the UTC day changes. Callers hold mu.
+func (st *keyState) rollover(now time.Time) {
+	day := now.UTC().Format("2006-01-02")
+	if st.day != day {
+		st.day = day
+		st.usage.TodayConversions = 0
+		st.usage.TodayBytes = 0
+	}
+}
+
+// allowRequest consumes a rate limit token and returns how long to wait if none is left
+func (st *keyState) allowRequest(now time.Time) (bool, time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.usage.Requests++
+	st.usage.LastUsed = now.Format(time.RFC3339)
+
+	if st.key.RateLimit <= 0 {
+		return true, 0
+	}
+
+	perSecond := float64(st.key.RateLimit) / 60
+	if !st.lastRefill.IsZero() {
+		st.tokens = math.Min(float64(st.key.Burst), st.tokens+now.Sub(st.lastRefill).Seconds()*perSecond)
+	}
+	st.lastRefill = now
+
+	if st.tokens < 1 {
+		st.usage.RateLimited++
+		return false, time.Duration((1 - st.tokens) / perSecond * float64(time.Second))
+	}
+
+	st.tokens--
+	return true, 0
+}
+
+// checkQuota reports an error if the key has used up its daily quota
+func (st *keyState) checkQuota(now time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.rollover(now)
+
+	if st.key.DailyConversions > 0 && st.usage.TodayConversions >= st.key.DailyConversions {
+		st.usage.QuotaExceeded++
+		return fmt.Errorf("daily conversion quota of %d exceeded", st.key.DailyConversions)
+	}
+	if st.key.DailyBytes > 0 && st.usage.TodayBytes >= st.key.DailyBytes {
+		st.usage.QuotaExceeded++
+		return fmt.Errorf("daily quota of %d bytes exceeded", st.key.DailyBytes)
+	}
+
+	return nil
+}
+
+// recordConversion adds a successful conversion to the usage counters
+func (st *keyState) recordConversion(now time.Time, size int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.rollover(now)
+	st.usage.Conversions++
+	st.usage.Bytes += int64(size)
+	st.usage.TodayConversions++
+	st.usage.TodayBytes += int64(size)
+}
+
+// allowsTemplate reports whether the key may use the named template
+func (st *keyState) allowsTemplate(name string) bool {
+	if len(st.key.Templates) == 0 {
+		return true
+	}
+	for _, allowed := range st.key.Templates {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// requestKey returns the authenticated key of the request, or nil when auth is disabled
+func requestKey(c *gin.Context) *keyState {
+	if value, ok := c.Get(apiKeyContextKey); ok {
+		return value.(*keyState)
+	}
+	return nil
+}
+
+// extractAPIKey reads the key from the X-API-Key or Authorization: Bearer headers
+func extractAPIKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return ""
+}
+
+// AuthMiddleware authenticates requests and applies per-key rate limits
+func (s *PDFService) AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.keys.Enabled() {
+			c.Next()
+			return
+		}
+
+		key := extractAPIKey(c)
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(c, "Missing API key"))
+			return
+		}
+
+		st := s.keys.Lookup(key)
+		if st == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(c, "Invalid API key"))
+			return
+		}
+
+		if ok, wait := st.allowRequest(time.Now()); !ok {
+			c.Header("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, errorBody(c, "Rate limit exceeded"))
+			return
+		}
+
+		c.Set(apiKeyContextKey, st)
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests that were not made with an admin key
+func (s *PDFService) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if st := requestKey(c); st == nil || !st.key.Admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, errorBody(c, "Admin API key required"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// KeyStatsHandler returns per-key usage counters
+func (s *PDFService) KeyStatsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"keys":      s.keys.Usage(),
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
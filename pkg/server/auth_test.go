@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestKeyStore(t *testing.T, keys string) *KeyStore {
+	t.Helper()
+	store, err := LoadKeyStore(&Config{APIKeys: keys, DefaultRateLimit: 2, DefaultDailyConversions: 1})
+	if err != nil {
+		t.Fatalf("Failed to load key store: %v", err)
+	}
+	return store
+}
+
+func TestLoadKeyStoreRejectsPlaintextKeys(t *testing.T) {
+	if _, err := LoadKeyStore(&Config{APIKeys: "lms=secret"}); err == nil {
+		t.Fatal("Expected error for non-hashed key")
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := &PDFService{keys: newTestKeyStore(t, "lms="+HashAPIKey("secret"))}
+
+	r := gin.New()
+	r.GET("/api/ping", service.AuthMiddleware(), func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"missing key", "", "", http.StatusUnauthorized},
+		{"wrong key", "X-API-Key", "nope", http.StatusUnauthorized},
+		{"api key header", "X-API-Key", "secret", http.StatusOK},
+		{"bearer token", "Authorization", "Bearer secret", http.StatusOK},
+		{"rate limited", "X-API-Key", "secret", http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/ping", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+}
+
+func TestDailyQuota(t *testing.T) {
+	st := newTestKeyStore(t, "lms="+HashAPIKey("secret")).Lookup("secret")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := st.checkQuota(now); err != nil {
+		t.Fatalf("Unexpected quota error: %v", err)
+	}
+	st.recordConversion(now, 1024)
+
+	if err := st.checkQuota(now); err == nil {
+		t.Fatal("Expected daily conversion quota to be exceeded")
+	}
+
+	if err := st.checkQuota(now.Add(24 * time.Hour)); err != nil {
+		t.Fatalf("Expected quota to reset on the next day, got: %v", err)
+	}
+}
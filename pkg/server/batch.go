@@ -0,0 +1,331 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// unsafeFilenameChars are replaced when deriving zip entry names from document names
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// BatchDocument is one document of a batch conversion
+type BatchDocument struct {
+	Name            string                 `json:"name"`
+	MarkdownContent string                 `json:"markdownContent"`
+	TypstContent    string                 `json:"typstContent"`
+	Template        string                 `json:"template"`
+	Options         map[string]interface{} `json:"options"`
+}
+
+// BatchRequest represents the JSON body of POST /api/batch
+type BatchRequest struct {
+	Documents []BatchDocument `json:"documents"`
+}
+
+// BatchManifestEntry reports the outcome of one batch document
+type BatchManifestEntry struct {
+	Index    int    `json:"index"`
+	Name     string `json:"name"`
+	File     string `json:"file,omitempty"`
+	Template string `json:"template,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Bytes    int    `json:"bytes,omitempty"`
+	Pages    int    `json:"pages,omitempty"`
+
+	Warnings []mdpdf.Diagnostic `json:"warnings,omitempty"`
+}
+
+// BatchManifest is written as manifest.json at the end of the batch zip
+type BatchManifest struct {
+	RequestID  string               `json:"requestId"`
+	Total      int                  `json:"total"`
+	Succeeded  int                  `json:"succeeded"`
+	Failed     int                  `json:"failed"`
+	DurationMs int64                `json:"durationMs"`
+	Documents  []BatchManifestEntry `json:"documents"`
+}
+
+// batchResult carries a finished document from a worker to the zip writer
+type batchResult struct {
+	entry BatchManifestEntry
+	pdf   []byte
+}
+
+// BatchHandler converts many documents in parallel and streams back a zip of PDFs
+// with a manifest.json. It accepts a JSON BatchRequest or a zip of .md files
+// (as the request body or a multipart "file" field, template from the "template" parameter).
+func (s *PDFService) BatchHandler(c *gin.Context) {
+	documents, err := s.parseBatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(c, err.Error()))
+		return
+	}
+
+	if len(documents) == 0 {
+		c.JSON(http.StatusBadRequest, errorBody(c, "Batch contains no documents"))
+		return
+	}
+	if len(documents) > s.config.MaxBatchSize {
+		c.JSON(http.StatusBadRequest, errorBody(c, fmt.Sprintf("Batch exceeds maximum of %d documents", s.config.MaxBatchSize)))
+		return
+	}
+
+	startTime := time.Now()
+	reqID := requestID(c)
+	requestLogger(c).Info("starting batch conversion", "documents", len(documents))
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="batch.zip"`)
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	manifest := BatchManifest{
+		RequestID: reqID,
+		Total:     len(documents),
+		Documents: make([]BatchManifestEntry, len(documents)),
+	}
+
+	// Write PDFs to the zip as they finish
+	usedNames := make(map[string]bool)
+	for res := range s.runBatch(c.Request.Context(), reqID, requestKey(c), documents) {
+		if res.entry.Status == "ok" {
+			res.entry.File = uniqueName(usedNames, res.entry.File)
+			if err := writeZipEntry(zw, res.entry.File, res.pdf); err != nil {
+				res.entry.Status = "error"
+				res.entry.Error = "Failed to write zip entry: " + err.Error()
+				res.entry.File = ""
+			}
+		}
+
+		if res.entry.Status == "ok" {
+			manifest.Succeeded++
+		} else {
+			manifest.Failed++
+		}
+		manifest.Documents[res.entry.Index] = res.entry
+		if zw.Flush() == nil {
+			c.Writer.Flush()
+		}
+	}
+
+	manifest.DurationMs = time.Since(startTime).Milliseconds()
+	manifestJSON, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := writeZipEntry(zw, "manifest.json", manifestJSON); err != nil {
+		requestLogger(c).Error("failed to write batch manifest", "error", err.Error())
+	}
+	if err := zw.Close(); err != nil {
+		requestLogger(c).Error("failed to finish batch zip", "error", err.Error())
+	}
+
+	requestLogger(c).Info("batch conversion finished",
+		"documents", manifest.Total,
+		"succeeded", manifest.Succeeded,
+		"failed", manifest.Failed,
+		"duration_ms", manifest.DurationMs,
+	)
+}
+
+// runBatch converts documents concurrently, at most one per compile slot, and
+// delivers the results in completion order
+func (s *PDFService) runBatch(ctx context.Context, reqID string, key *keyState, documents []BatchDocument) <-chan batchResult {
+	results := make(chan batchResult)
+	slots := make(chan struct{}, s.pool.Size())
+
+	var wg sync.WaitGroup
+	for i, doc := range documents {
+		wg.Add(1)
+		go func(i int, doc BatchDocument) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			results <- s.convertBatchDocument(ctx, reqID, key, i, doc)
+		}(i, doc)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// convertBatchDocument converts a single batch document, never failing the batch
+func (s *PDFService) convertBatchDocument(ctx context.Context, reqID string, key *keyState, index int, doc BatchDocument) batchResult {
+	name := doc.Name
+	if name == "" {
+		name = fmt.Sprintf("document-%d", index+1)
+	}
+	entry := BatchManifestEntry{Index: index, Name: name, Status: "error"}
+
+	var typstContent, template string
+	switch {
+	case doc.MarkdownContent != "":
+		var cerr *conversionError
+		typstContent, template, cerr = s.renderMarkdown(key, doc.MarkdownContent, doc.Template)
+		if cerr != nil {
+			entry.Error = cerr.Message
+			return batchResult{entry: entry}
+		}
+	case doc.TypstContent != "":
+		if int64(len(doc.TypstContent)) > s.config.MaxFileSize {
+			entry.Error = "Content exceeds maximum file size limit"
+			return batchResult{entry: entry}
+		}
+		typstContent, template = doc.TypstContent, rawTemplate
+	default:
+		entry.Error = "Missing markdownContent or typstContent"
+		return batchResult{entry: entry}
+	}
+	entry.Template = template
+
+	result, cerr := s.compileTypst(ctx, reqID, key, typstContent, template, nil)
+	if cerr != nil {
+		entry.Error = cerr.Message
+		return batchResult{entry: entry}
+	}
+
+	entry.Status = "ok"
+	entry.Bytes = len(result.PDF)
+	entry.Pages = result.Pages
+	entry.Warnings = result.Warnings
+	entry.File = pdfFilename(doc.Options, sanitizeFilename(name)+".pdf")
+	return batchResult{entry: entry, pdf: result.PDF}
+}
+
+// parseBatch reads the batch documents from a JSON body or an uploaded zip of markdown files
+func (s *PDFService) parseBatch(c *gin.Context) ([]BatchDocument, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.config.MaxFileSize)
+
+	contentType := c.ContentType()
+	switch {
+	case contentType == "application/json":
+		var req BatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, fmt.Errorf("Invalid request format: %w", err)
+		}
+		return req.Documents, nil
+
+	case contentType == "application/zip":
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read zip: %w", err)
+		}
+		return s.documentsFromZip(data, c.Query("template"))
+
+	case strings.HasPrefix(contentType, "multipart/form-data"):
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("Missing zip upload in form field \"file\": %w", err)
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open upload: %w", err)
+		}
+		defer f.Close()
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read upload: %w", err)
+		}
+		template := c.PostForm("template")
+		if template == "" {
+			template = c.Query("template")
+		}
+		return s.documentsFromZip(data, template)
+
+	default:
+		return nil, fmt.Errorf("Unsupported content type %q (use application/json, application/zip or multipart/form-data)", contentType)
+	}
+}
+
+// documentsFromZip turns every .md file of a zip archive into a batch document
+func (s *PDFService) documentsFromZip(data []byte, template string) ([]BatchDocument, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid zip archive: %w", err)
+	}
+
+	var documents []BatchDocument
+	var total int64
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() || !strings.EqualFold(path.Ext(file.Name), ".md") {
+			continue
+		}
+
+		// Guard against zip bombs: the decompressed total is bounded like a single request
+		total += int64(file.UncompressedSize64)
+		if total > s.config.MaxFileSize {
+			return nil, fmt.Errorf("Zip contents exceed maximum file size limit")
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read %s from zip: %w", file.Name, err)
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, s.config.MaxFileSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read %s from zip: %w", file.Name, err)
+		}
+
+		name := strings.TrimSuffix(file.Name, path.Ext(file.Name))
+		documents = append(documents, BatchDocument{
+			Name:            name,
+			MarkdownContent: string(content),
+			Template:        template,
+			Options:         map[string]interface{}{"filename": sanitizeFilename(name) + ".pdf"},
+		})
+	}
+
+	return documents, nil
+}
+
+// writeZipEntry adds a file to the zip archive
+func writeZipEntry(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// sanitizeFilename flattens a document name into a safe zip entry name
+func sanitizeFilename(name string) string {
+	name = unsafeFilenameChars.ReplaceAllString(strings.ReplaceAll(name, "/", "_"), "-")
+	name = strings.Trim(name, ".-")
+	if name == "" {
+		name = "document"
+	}
+	return name
+}
+
+// uniqueName returns name, or name with a numeric suffix if it has been used already
+func uniqueName(used map[string]bool, name string) string {
+	name = sanitizeFilename(name)
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readBatchZip returns the files of a batch response and its decoded manifest
+func readBatchZip(t *testing.T, body []byte) (map[string][]byte, BatchManifest) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("Response is not a zip: %v", err)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	var manifest BatchManifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatalf("Invalid manifest: %v", err)
+	}
+	return files, manifest
+}
+
+func TestBatchJSON(t *testing.T) {
+	_, r := newTestService(t)
+
+	w := postJSON(t, r, "/api/batch", BatchRequest{Documents: []BatchDocument{
+		{Name: "first", TypstContent: "= First"},
+		{Name: "broken", TypstContent: "#undefined-function()"},
+		{Name: "first", TypstContent: "= Second"},
+		{Name: "empty"},
+	}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	files, manifest := readBatchZip(t, w.Body.Bytes())
+	if manifest.Succeeded != 2 || manifest.Failed != 2 {
+		t.Fatalf("Expected 2 succeeded and 2 failed, got %+v", manifest)
+	}
+	for _, name := range []string{"first.pdf", "first-2.pdf"} {
+		if !bytes.HasPrefix(files[name], []byte("%PDF")) {
+			t.Errorf("Missing PDF %s in batch zip", name)
+		}
+	}
+	if manifest.Documents[1].Status != "error" || manifest.Documents[1].Error == "" {
+		t.Errorf("Expected error entry for broken document, got %+v", manifest.Documents[1])
+	}
+}
+
+func TestBatchZipUpload(t *testing.T) {
+	s, r := newTestService(t)
+	if err := os.WriteFile(filepath.Join(s.config.TemplateDir, "plain.typ"), []byte("{{Placeholder Markdown}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var upload bytes.Buffer
+	zw := zip.NewWriter(&upload)
+	for _, name := range []string{"week1/quiz.md", "week2/quiz.md", "notes.txt"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte("Hello from " + name))
+	}
+	zw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/batch?template=plain", &upload)
+	req.Header.Set("Content-Type", "application/zip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	files, manifest := readBatchZip(t, w.Body.Bytes())
+	if manifest.Total != 2 || manifest.Succeeded != 2 {
+		t.Fatalf("Expected 2 converted markdown files, got %+v", manifest)
+	}
+	for _, name := range []string{"week1_quiz.pdf", "week2_quiz.pdf"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Missing %s in batch zip", name)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// resultCache is an LRU cache of conversion results keyed by the template and the
+// hash of the Typst source
+type resultCache struct {
+	capacity int
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type cacheEntry struct {
+	key    string
+	result *mdpdf.ConvertResult
+}
+
+// newResultCache creates a cache holding up to capacity PDFs. A capacity of 0 disables caching.
+func newResultCache(capacity int) *resultCache {
+	return &resultCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// cacheKey returns the cache key for a template, the Typst source rendered from
+// it and the assets it can read
+func cacheKey(template, templateHash, typstContent string, assets map[string][]byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", template, templateHash)
+	h.Write([]byte(typstContent))
+	for _, name := range sortedKeys(assets) {
+		sum := sha256.Sum256(assets[name])
+		fmt.Fprintf(h, "\x00%s\x00%x", name, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns a copy of the cached result for key, marked as a cache hit that
+// took no compile time
+func (rc *resultCache) Get(key string) (*mdpdf.ConvertResult, bool) {
+	if rc.capacity <= 0 {
+		return nil, false
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	elem, ok := rc.items[key]
+	if !ok {
+		rc.misses.Add(1)
+		return nil, false
+	}
+
+	rc.hits.Add(1)
+	rc.order.MoveToFront(elem)
+	result := *elem.Value.(*cacheEntry).result
+	result.CacheHit = true
+	result.CompileDuration = 0
+	return &result, true
+}
+
+// Add stores a result, evicting the least recently used entry when full
+func (rc *resultCache) Add(key string, result *mdpdf.ConvertResult) {
+	if rc.capacity <= 0 {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if elem, ok := rc.items[key]; ok {
+		rc.order.MoveToFront(elem)
+		return
+	}
+
+	rc.items[key] = rc.order.PushFront(&cacheEntry{key: key, result: result})
+	if rc.order.Len() > rc.capacity {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Hits returns the number of cache hits
+func (rc *resultCache) Hits() int64 {
+	return rc.hits.Load()
+}
+
+// Misses returns the number of cache misses
+func (rc *resultCache) Misses() int64 {
+	return rc.misses.Load()
+}
+
+// HitRatio returns hits divided by lookups, or 0 before the first lookup
+func (rc *resultCache) HitRatio() float64 {
+	hits, misses := rc.Hits(), rc.Misses()
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// Compile isolation modes
+const (
+	isolationNone    = "none"    // compile in a subprocess of the server
+	isolationProcess = "process" // compile in recycled worker processes with resource limits
+)
+
+// compiler turns Typst source into a PDF inside a sandboxed job root
+type compiler interface {
+	// Compile compiles typstContent into a result holding the PDF and the compiler
+	// warnings. When ctx is done the compilation is killed.
+	Compile(ctx context.Context, typstContent string, assets map[string][]byte) (*mdpdf.ConvertResult, error)
+	// Close releases resources such as idle worker processes
+	Close()
+}
+
+// newCompiler returns the compiler for the configured isolation mode
+func newCompiler(config *Config) (compiler, error) {
+	switch config.CompileIsolation {
+	case isolationNone, "":
+		return &inProcessCompiler{tempDir: config.TempDir, templateDir: config.TemplateDir}, nil
+	case isolationProcess:
+		return newProcessCompiler(config)
+	default:
+		return nil, fmt.Errorf("unknown compile isolation mode %q", config.CompileIsolation)
+	}
+}
+
+// inProcessCompiler compiles directly from the server process
+type inProcessCompiler struct {
+	tempDir     string
+	templateDir string
+}
+
+func (c *inProcessCompiler) Compile(ctx context.Context, typstContent string, assets map[string][]byte) (*mdpdf.ConvertResult, error) {
+	return compileSandboxed(ctx, c.tempDir, c.templateDir, typstContent, assets)
+}
+
+func (c *inProcessCompiler) Close() {}
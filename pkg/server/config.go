@@ -0,0 +1,374 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+	"gopkg.in/yaml.v3"
+)
+
+// Server configuration
+type Config struct {
+	Port            string        `yaml:"port"`
+	APIOnly         bool          `yaml:"api_only"`
+	TempDir         string        `yaml:"temp_dir"`
+	SkeletonPath    string        `yaml:"skeleton_path"`
+	TemplateDir     string        `yaml:"template_dir"`
+	MaxFileSize     int64         `yaml:"max_file_size"`
+	MaxPages        int           `yaml:"max_pages"`        // page limit of a generated PDF, 0 = unlimited
+	MaxOutputBytes  int64         `yaml:"max_output_bytes"` // size limit of a generated PDF, 0 = unlimited
+	TimeoutDuration time.Duration `yaml:"timeout_duration"`
+	LogLevel        string        `yaml:"log_level"`
+
+	// ShutdownGracePeriod bounds how long SIGTERM waits for in-flight conversions
+	ShutdownGracePeriod time.Duration `yaml:"shutdown_grace_period"`
+
+	// Compile concurrency and result caching
+	MaxConcurrentJobs int `yaml:"max_concurrent_jobs"`
+	MaxQueueDepth     int `yaml:"max_queue_depth"` // readiness fails at this many waiting conversions, 0 = never
+	CacheSize         int `yaml:"cache_size"`
+	MaxBatchSize      int `yaml:"max_batch_size"`
+
+	// Compile isolation: "none" or "process" (worker subprocesses with resource limits)
+	CompileIsolation  string        `yaml:"compile_isolation"`
+	WorkerMemoryLimit int64         `yaml:"worker_memory_limit"` // bytes, 0 = unlimited
+	WorkerCPULimit    time.Duration `yaml:"worker_cpu_limit"`    // CPU time per compile, 0 = unlimited
+	WorkerMaxJobs     int           `yaml:"worker_max_jobs"`     // jobs before a worker is replaced
+
+	// Raw Typst input: "allowed", "disabled" or "authenticated" (API key required),
+	// optionally limited to imports of the listed packages
+	RawTypstMode     string   `yaml:"raw_typst_mode"`
+	RawTypstPackages []string `yaml:"raw_typst_packages"`
+
+	// Async jobs and completion webhooks
+	JobRetention        time.Duration `yaml:"job_retention"`
+	MaxJobs             int           `yaml:"max_jobs"`      // stored async jobs, 0 = unlimited
+	MaxJobBytes         int64         `yaml:"max_job_bytes"` // total size of stored job PDFs, 0 = unlimited
+	PublicURL           string        `yaml:"public_url"`    // base URL for download links, default: derived from the request
+	WebhookSecret       string        `yaml:"webhook_secret"`
+	WebhookMaxAttempts  int           `yaml:"webhook_max_attempts"`
+	WebhookBackoff      time.Duration `yaml:"webhook_backoff"` // delay before the first retry, doubled on each further retry
+	WebhookTimeout      time.Duration `yaml:"webhook_timeout"`
+	WebhookAllowPrivate bool          `yaml:"webhook_allow_private"` // allow callbacks to loopback, private and link-local addresses
+
+	// SelfTestInterval is how often the background compilation self-test runs
+	SelfTestInterval time.Duration `yaml:"self_test_interval"`
+
+	// API key authentication (disabled when no keys are configured)
+	APIKeysFile             string `yaml:"api_keys_file"`
+	APIKeys                 string `yaml:"api_keys"`
+	DefaultRateLimit        int    `yaml:"default_rate_limit"`
+	DefaultDailyConversions int    `yaml:"default_daily_conversions"`
+	DefaultDailyBytes       int64  `yaml:"default_daily_bytes"`
+
+	// CORS policy (nil origins = allow all in debug mode, same-origin only in release mode)
+	CORSAllowedOrigins   []string      `yaml:"cors_allowed_origins"`
+	CORSAllowedMethods   []string      `yaml:"cors_allowed_methods"`
+	CORSAllowedHeaders   []string      `yaml:"cors_allowed_headers"`
+	CORSAllowCredentials bool          `yaml:"cors_allow_credentials"`
+	CORSMaxAge           time.Duration `yaml:"cors_max_age"`
+}
+
+// DefaultConfig returns the built-in configuration
+func DefaultConfig() *Config {
+	maxConcurrentJobs := runtime.NumCPU()
+
+	return &Config{
+		Port:                "3000",
+		TempDir:             "./temp",
+		SkeletonPath:        "", // the embedded default template
+		TemplateDir:         "./templates",
+		MaxFileSize:         50 * 1024 * 1024, // 50MB
+		MaxPages:            1000,
+		MaxOutputBytes:      100 * 1024 * 1024, // 100MB
+		TimeoutDuration:     30 * time.Second,
+		LogLevel:            "info",
+		ShutdownGracePeriod: 30 * time.Second,
+		MaxConcurrentJobs:   maxConcurrentJobs,
+		MaxQueueDepth:       4 * maxConcurrentJobs,
+		CacheSize:           100,
+		MaxBatchSize:        100,
+		CompileIsolation:    isolationNone,
+		WorkerMemoryLimit:   1 << 30, // 1GB
+		WorkerCPULimit:      time.Minute,
+		WorkerMaxJobs:       100,
+		RawTypstMode:        mdpdf.RawTypstAllowed,
+		JobRetention:        time.Hour,
+		MaxJobs:             1000,
+		MaxJobBytes:         1 << 30, // 1GB
+		WebhookMaxAttempts:  5,
+		WebhookBackoff:      time.Second,
+		WebhookTimeout:      10 * time.Second,
+		SelfTestInterval:    time.Minute,
+		DefaultRateLimit:    60, // requests per minute
+		CORSMaxAge:          12 * time.Hour,
+	}
+}
+
+// setting binds one configuration value to its command line flag and environment variable.
+// The flag name with dashes replaced by underscores is the key in the config file.
+type setting struct {
+	flag   string
+	env    string
+	usage  string
+	isBool bool
+	isList bool // an empty value is meaningful (an empty list) rather than unset
+	set    func(cfg *Config, value string) error
+}
+
+// bind creates a setting that parses a string into the field selected by field
+func bind[T any](flag, env, usage string, field func(*Config) *T, parse func(string) (T, error)) setting {
+	_, isBool := any(new(T)).(*bool)
+	_, isList := any(new(T)).(*[]string)
+	return setting{
+		flag:   flag,
+		env:    env,
+		usage:  usage,
+		isBool: isBool,
+		isList: isList,
+		set: func(cfg *Config, value string) error {
+			parsed, err := parse(value)
+			if err != nil {
+				return err
+			}
+			*field(cfg) = parsed
+			return nil
+		},
+	}
+}
+
+func parseString(value string) (string, error) { return value, nil }
+func parseInt64(value string) (int64, error)   { return strconv.ParseInt(value, 10, 64) }
+func parseList(value string) ([]string, error) { return splitList(value), nil }
+
+// settings lists every configurable value
+func settings() []setting {
+	return []setting{
+		bind("port", "PORT", "HTTP port to listen on", func(c *Config) *string { return &c.Port }, parseString),
+		bind("api-only", "API_ONLY", "Run in API-only mode (no web UI)", func(c *Config) *bool { return &c.APIOnly }, strconv.ParseBool),
+		bind("temp-dir", "TEMP_DIR", "Temporary files directory", func(c *Config) *string { return &c.TempDir }, parseString),
+		bind("skeleton-path", "SKELETON_PATH", "Default Typst template file (empty for the built-in template)", func(c *Config) *string { return &c.SkeletonPath }, parseString),
+		bind("template-dir", "TEMPLATE_DIR", "Directory of additional named templates", func(c *Config) *string { return &c.TemplateDir }, parseString),
+		bind("max-file-size", "MAX_FILE_SIZE", "Maximum input size in bytes", func(c *Config) *int64 { return &c.MaxFileSize }, parseInt64),
+		bind("max-pages", "MAX_PAGES", "Maximum pages of a generated PDF (0 = unlimited)", func(c *Config) *int { return &c.MaxPages }, strconv.Atoi),
+		bind("max-output-bytes", "MAX_OUTPUT_BYTES", "Maximum size of a generated PDF in bytes (0 = unlimited)", func(c *Config) *int64 { return &c.MaxOutputBytes }, parseInt64),
+		bind("timeout-duration", "TIMEOUT_DURATION", "Conversion timeout", func(c *Config) *time.Duration { return &c.TimeoutDuration }, time.ParseDuration),
+		bind("log-level", "LOG_LEVEL", "Log level: debug, info, warn or error", func(c *Config) *string { return &c.LogLevel }, parseString),
+		bind("shutdown-grace-period", "SHUTDOWN_GRACE_PERIOD", "Time to wait for in-flight conversions on shutdown", func(c *Config) *time.Duration { return &c.ShutdownGracePeriod }, time.ParseDuration),
+		bind("max-concurrent-jobs", "MAX_CONCURRENT_JOBS", "Maximum concurrent Typst compilations", func(c *Config) *int { return &c.MaxConcurrentJobs }, strconv.Atoi),
+		bind("max-queue-depth", "MAX_QUEUE_DEPTH", "Queued conversions at which readiness fails (0 = never)", func(c *Config) *int { return &c.MaxQueueDepth }, strconv.Atoi),
+		bind("cache-size", "CACHE_SIZE", "Number of PDFs kept in the result cache (0 disables)", func(c *Config) *int { return &c.CacheSize }, strconv.Atoi),
+		bind("max-batch-size", "MAX_BATCH_SIZE", "Maximum documents per batch request", func(c *Config) *int { return &c.MaxBatchSize }, strconv.Atoi),
+		bind("compile-isolation", "COMPILE_ISOLATION", "Compile isolation mode: none or process", func(c *Config) *string { return &c.CompileIsolation }, parseString),
+		bind("worker-memory-limit", "WORKER_MEMORY_LIMIT", "Address space limit of compile workers in bytes (0 = unlimited)", func(c *Config) *int64 { return &c.WorkerMemoryLimit }, parseInt64),
+		bind("worker-cpu-limit", "WORKER_CPU_LIMIT", "CPU time limit per compile in process isolation (0 = unlimited)", func(c *Config) *time.Duration { return &c.WorkerCPULimit }, time.ParseDuration),
+		bind("worker-max-jobs", "WORKER_MAX_JOBS", "Jobs a compile worker runs before it is replaced", func(c *Config) *int { return &c.WorkerMaxJobs }, strconv.Atoi),
+		bind("raw-typst-mode", "RAW_TYPST_MODE", "Raw Typst input: allowed, disabled or authenticated", func(c *Config) *string { return &c.RawTypstMode }, parseString),
+		bind("raw-typst-packages", "RAW_TYPST_PACKAGES", "Comma separated packages raw Typst may import, e.g. @preview/cetz (empty for all)", func(c *Config) *[]string { return &c.RawTypstPackages }, parseList),
+		bind("job-retention", "JOB_RETENTION", "How long finished async jobs and their PDFs are kept", func(c *Config) *time.Duration { return &c.JobRetention }, time.ParseDuration),
+		bind("max-jobs", "MAX_JOBS", "Maximum stored async jobs (0 = unlimited)", func(c *Config) *int { return &c.MaxJobs }, strconv.Atoi),
+		bind("max-job-bytes", "MAX_JOB_BYTES", "Maximum total size of stored async job PDFs in bytes (0 = unlimited)", func(c *Config) *int64 { return &c.MaxJobBytes }, parseInt64),
+		bind("public-url", "PUBLIC_URL", "Public base URL used in job download links", func(c *Config) *string { return &c.PublicURL }, parseString),
+		bind("webhook-secret", "WEBHOOK_SECRET", "HMAC secret for signing job callbacks (callbacks disabled when empty)", func(c *Config) *string { return &c.WebhookSecret }, parseString),
+		bind("webhook-max-attempts", "WEBHOOK_MAX_ATTEMPTS", "Delivery attempts per job callback", func(c *Config) *int { return &c.WebhookMaxAttempts }, strconv.Atoi),
+		bind("webhook-backoff", "WEBHOOK_BACKOFF", "Delay before the first callback retry, doubled per retry", func(c *Config) *time.Duration { return &c.WebhookBackoff }, time.ParseDuration),
+		bind("webhook-timeout", "WEBHOOK_TIMEOUT", "Timeout of a single callback delivery attempt", func(c *Config) *time.Duration { return &c.WebhookTimeout }, time.ParseDuration),
+		bind("webhook-allow-private", "WEBHOOK_ALLOW_PRIVATE", "Allow job callbacks to loopback, private and link-local addresses", func(c *Config) *bool { return &c.WebhookAllowPrivate }, strconv.ParseBool),
+		bind("self-test-interval", "SELF_TEST_INTERVAL", "Background compilation self-test interval", func(c *Config) *time.Duration { return &c.SelfTestInterval }, time.ParseDuration),
+		bind("api-keys-file", "API_KEYS_FILE", "JSON file with API key definitions", func(c *Config) *string { return &c.APIKeysFile }, parseString),
+		bind("api-keys", "API_KEYS", "Comma separated name=sha256hex API keys", func(c *Config) *string { return &c.APIKeys }, parseString),
+		bind("default-rate-limit", "DEFAULT_RATE_LIMIT", "Requests per minute for API_KEYS entries (0 = unlimited)", func(c *Config) *int { return &c.DefaultRateLimit }, strconv.Atoi),
+		bind("default-daily-conversions", "DEFAULT_DAILY_CONVERSIONS", "Daily conversions for API_KEYS entries (0 = unlimited)", func(c *Config) *int { return &c.DefaultDailyConversions }, strconv.Atoi),
+		bind("default-daily-bytes", "DEFAULT_DAILY_BYTES", "Daily PDF bytes for API_KEYS entries (0 = unlimited)", func(c *Config) *int64 { return &c.DefaultDailyBytes }, parseInt64),
+		bind("cors-allowed-origins", "CORS_ALLOWED_ORIGINS", "Comma separated allowed origins (\"*\" for all, empty for same-origin only)", func(c *Config) *[]string { return &c.CORSAllowedOrigins }, parseList),
+		bind("cors-allowed-methods", "CORS_ALLOWED_METHODS", "Comma separated allowed CORS methods", func(c *Config) *[]string { return &c.CORSAllowedMethods }, parseList),
+		bind("cors-allowed-headers", "CORS_ALLOWED_HEADERS", "Comma separated allowed CORS request headers", func(c *Config) *[]string { return &c.CORSAllowedHeaders }, parseList),
+		bind("cors-allow-credentials", "CORS_ALLOW_CREDENTIALS", "Allow credentials on cross-origin requests", func(c *Config) *bool { return &c.CORSAllowCredentials }, strconv.ParseBool),
+		bind("cors-max-age", "CORS_MAX_AGE", "CORS preflight cache duration", func(c *Config) *time.Duration { return &c.CORSMaxAge }, time.ParseDuration),
+	}
+}
+
+// LoadConfig registers the configuration flags on fs, parses args and builds the
+// configuration. Precedence is flags > environment > config file > defaults.
+// The config file is taken from -config or CONFIG_FILE.
+func LoadConfig(fs *flag.FlagSet, args []string) (*Config, error) {
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file")
+
+	type flagValue struct {
+		setting setting
+		value   string
+	}
+	var flagValues []flagValue
+
+	all := settings()
+	for _, st := range all {
+		st := st
+		record := func(value string) error {
+			flagValues = append(flagValues, flagValue{st, value})
+			return nil
+		}
+		usage := fmt.Sprintf("%s (env %s)", st.usage, st.env)
+		if st.isBool {
+			fs.BoolFunc(st.flag, usage, record)
+		} else {
+			fs.Func(st.flag, usage, record)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	config := DefaultConfig()
+
+	if *configPath != "" {
+		if err := config.loadFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, st := range all {
+		if value, ok := os.LookupEnv(st.env); ok && (value != "" || st.isList) {
+			if err := st.set(config, value); err != nil {
+				return nil, fmt.Errorf("invalid %s=%q: %w", st.env, value, err)
+			}
+		}
+	}
+
+	for _, fv := range flagValues {
+		if err := fv.setting.set(config, fv.value); err != nil {
+			return nil, fmt.Errorf("invalid -%s=%q: %w", fv.setting.flag, fv.value, err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// loadFile overlays the YAML config file at path. Unknown keys are rejected.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate rejects configurations the service cannot run with
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port > 0 && port < 65536, "port must be a number between 1 and 65535 (got %q)", c.Port)
+	check(c.TempDir != "", "temp_dir must not be empty")
+	check(c.MaxFileSize > 0, "max_file_size must be positive (got %d)", c.MaxFileSize)
+	check(c.MaxPages >= 0, "max_pages must not be negative (got %d)", c.MaxPages)
+	check(c.MaxOutputBytes >= 0, "max_output_bytes must not be negative (got %d)", c.MaxOutputBytes)
+	check(c.TimeoutDuration > 0, "timeout_duration must be positive (got %v)", c.TimeoutDuration)
+	_, err = newLogger(io.Discard, c.LogLevel)
+	check(err == nil, "log_level must be debug, info, warn or error (got %q)", c.LogLevel)
+	check(c.ShutdownGracePeriod >= 0, "shutdown_grace_period must not be negative (got %v)", c.ShutdownGracePeriod)
+	check(c.MaxConcurrentJobs > 0, "max_concurrent_jobs must be at least 1 (got %d)", c.MaxConcurrentJobs)
+	check(c.MaxQueueDepth >= 0, "max_queue_depth must not be negative (got %d)", c.MaxQueueDepth)
+	check(c.CacheSize >= 0, "cache_size must not be negative (got %d)", c.CacheSize)
+	check(c.MaxBatchSize > 0, "max_batch_size must be at least 1 (got %d)", c.MaxBatchSize)
+	check(c.CompileIsolation == isolationNone || c.CompileIsolation == isolationProcess, "compile_isolation must be none or process (got %q)", c.CompileIsolation)
+	check(c.WorkerMemoryLimit >= 0, "worker_memory_limit must not be negative (got %d)", c.WorkerMemoryLimit)
+	check(c.WorkerCPULimit >= 0, "worker_cpu_limit must not be negative (got %v)", c.WorkerCPULimit)
+	check(c.WorkerMaxJobs > 0, "worker_max_jobs must be at least 1 (got %d)", c.WorkerMaxJobs)
+	check(c.rawTypstPolicy().Validate() == nil, "raw_typst_mode must be allowed, disabled or authenticated (got %q)", c.RawTypstMode)
+	check(c.JobRetention > 0, "job_retention must be positive (got %v)", c.JobRetention)
+	check(c.MaxJobs >= 0, "max_jobs must not be negative (got %d)", c.MaxJobs)
+	check(c.MaxJobBytes >= 0, "max_job_bytes must not be negative (got %d)", c.MaxJobBytes)
+	if c.PublicURL != "" {
+		u, err := url.Parse(c.PublicURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "public_url must be an absolute http(s) URL (got %q)", c.PublicURL)
+	}
+	check(c.WebhookMaxAttempts > 0, "webhook_max_attempts must be at least 1 (got %d)", c.WebhookMaxAttempts)
+	check(c.WebhookBackoff >= 0, "webhook_backoff must not be negative (got %v)", c.WebhookBackoff)
+	check(c.WebhookTimeout > 0, "webhook_timeout must be positive (got %v)", c.WebhookTimeout)
+	check(c.SelfTestInterval > 0, "self_test_interval must be positive (got %v)", c.SelfTestInterval)
+	check(c.DefaultRateLimit >= 0, "default_rate_limit must not be negative (got %d)", c.DefaultRateLimit)
+	check(c.DefaultDailyConversions >= 0, "default_daily_conversions must not be negative (got %d)", c.DefaultDailyConversions)
+	check(c.DefaultDailyBytes >= 0, "default_daily_bytes must not be negative (got %d)", c.DefaultDailyBytes)
+	check(c.CORSMaxAge >= 0, "cors_max_age must not be negative (got %v)", c.CORSMaxAge)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// rawTypstPolicy returns the policy for user-supplied Typst source
+func (c *Config) rawTypstPolicy() mdpdf.RawTypstPolicy {
+	return mdpdf.RawTypstPolicy{Mode: c.RawTypstMode, AllowedPackages: c.RawTypstPackages}
+}
+
+// WriteYAML writes the configuration in config file format
+func (c *Config) WriteYAML(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// MarshalYAML writes durations as strings such as "30s" and leaves out unset
+// lists, so the output loads back into the same configuration. An empty list
+// is kept: empty CORS origins mean same-origin only, unset ones the default.
+func (c *Config) MarshalYAML() (interface{}, error) {
+	type plain Config
+	var node yaml.Node
+	if err := node.Encode((*plain)(c)); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]reflect.Value)
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		values[name] = v.Field(i)
+	}
+
+	content := node.Content[:0]
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		field := values[key.Value]
+		if field.Kind() == reflect.Slice && field.IsNil() {
+			continue
+		}
+		if d, ok := field.Interface().(time.Duration); ok {
+			value.SetString(d.String())
+		}
+		content = append(content, key, value)
+	}
+	node.Content = content
+	return &node, nil
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "port: \"4000\"\ncache_size: 5\ntimeout_duration: 10s\nlog_level: debug\n")
+	t.Setenv("CACHE_SIZE", "7")
+	t.Setenv("LOG_LEVEL", "warn")
+
+	config, err := LoadConfig(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path, "-log-level", "error"})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if config.Port != "4000" {
+		t.Errorf("Expected port from file, got %q", config.Port)
+	}
+	if config.TimeoutDuration != 10*time.Second {
+		t.Errorf("Expected timeout from file, got %v", config.TimeoutDuration)
+	}
+	if config.CacheSize != 7 {
+		t.Errorf("Expected env to override file, got cache size %d", config.CacheSize)
+	}
+	if config.LogLevel != "error" {
+		t.Errorf("Expected flag to override env, got log level %q", config.LogLevel)
+	}
+	if config.MaxFileSize != DefaultConfig().MaxFileSize {
+		t.Errorf("Expected default max file size, got %d", config.MaxFileSize)
+	}
+}
+
+func TestLoadConfigRejectsBadValues(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		file string
+		args []string
+	}{
+		{name: "unparsable env", env: map[string]string{"MAX_FILE_SIZE": "50MB"}},
+		{name: "unparsable duration", env: map[string]string{"TIMEOUT_DURATION": "thirty"}},
+		{name: "invalid port", args: []string{"-port", "http"}},
+		{name: "negative size", args: []string{"-max-file-size", "-1"}},
+		{name: "unknown file key", file: "prot: \"3000\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeConfigFile(t, tt.file))
+			}
+
+			if _, err := LoadConfig(flag.NewFlagSet("test", flag.ContinueOnError), args); err == nil {
+				t.Fatal("Expected configuration error")
+			}
+		})
+	}
+}
+
+func TestConfigYAMLRoundTrip(t *testing.T) {
+	want := DefaultConfig()
+	want.CORSAllowedOrigins = []string{} // same-origin only, distinct from unset
+	want.RawTypstPackages = []string{"@preview/cetz"}
+	want.WebhookBackoff = 1500 * time.Millisecond
+
+	var out strings.Builder
+	if err := want.WriteYAML(&out); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if !strings.Contains(out.String(), "timeout_duration: 30s") {
+		t.Errorf("Expected durations as strings:\n%s", out.String())
+	}
+
+	config, err := LoadConfig(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", writeConfigFile(t, out.String())})
+	if err != nil {
+		t.Fatalf("Printed config does not load: %v\n%s", err, out.String())
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Config changed in the round trip:\nwant %+v\ngot  %+v", want, config)
+	}
+}
@@ -0,0 +1,284 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// conversionError is a failed conversion together with the HTTP status to report
+type conversionError struct {
+	Status  int
+	Outcome string
+	Message string
+}
+
+func (e *conversionError) Error() string {
+	return e.Message
+}
+
+// converter returns a converter enforcing the configured input size limit and
+// raw Typst policy. The service compiles in its own sandbox, so it only renders
+// and checks input with it.
+func (s *PDFService) converter() (*mdpdf.Converter, error) {
+	opts := mdpdf.DefaultOptions()
+	opts.MaxFileSize = s.config.MaxFileSize
+	opts.RawTypst = s.config.rawTypstPolicy()
+	return mdpdf.NewConverter(opts)
+}
+
+// callerContext marks requests with an API key as authenticated for the raw Typst policy
+func callerContext(key *keyState) context.Context {
+	if key != nil {
+		return mdpdf.WithAuthenticatedCaller(context.Background())
+	}
+	return context.Background()
+}
+
+// inputError maps an error of the converter rejecting input to its status.
+// Policy errors are reported after prefix.
+func inputError(err error, prefix string) *conversionError {
+	var limitErr *mdpdf.LimitError
+	switch {
+	case errors.As(err, &limitErr):
+		return &conversionError{http.StatusBadRequest, outcomeRejected, "Content exceeds maximum file size limit"}
+	case errors.Is(err, mdpdf.ErrRawTypstNotAllowed):
+		return &conversionError{http.StatusForbidden, outcomeRejected, prefix + err.Error()}
+	default:
+		return &conversionError{http.StatusInternalServerError, outcomeRejected, "Skeleton template error: " + err.Error()}
+	}
+}
+
+// renderMarkdown validates markdown input and renders it into the requested template.
+// It returns the Typst source and the resolved template name.
+func (s *PDFService) renderMarkdown(key *keyState, markdownContent, template string) (string, string, *conversionError) {
+	name, templatePath, err := s.resolveTemplate(template)
+	if err != nil {
+		return "", "", &conversionError{http.StatusBadRequest, outcomeRejected, err.Error()}
+	}
+
+	if key != nil && !key.allowsTemplate(name) {
+		return "", "", &conversionError{http.StatusForbidden, outcomeRejected, fmt.Sprintf("API key is not allowed to use template %q", name)}
+	}
+
+	converter, err := s.converter()
+	if err != nil {
+		return "", "", inputError(err, "")
+	}
+	// The converter applies the size limit and the raw Typst policy to
+	// markdown, which can embed Typst code in <!--raw-typst--> comments
+	typstContent, err := converter.RenderMarkdown(callerContext(key), markdownContent, mdpdf.WithTemplate(templatePath))
+	if err != nil {
+		return "", "", inputError(err, "Markdown input rejected: ")
+	}
+
+	return typstContent, name, nil
+}
+
+// rawTemplate is the template name of user-supplied Typst source
+const rawTemplate = "raw"
+
+// checkRawTypst applies the size limit and the raw Typst policy, treating
+// requests with an API key as authenticated
+func (s *PDFService) checkRawTypst(key *keyState, typstContent string) *conversionError {
+	converter, err := s.converter()
+	if err == nil {
+		err = converter.CheckTypst(callerContext(key), typstContent)
+	}
+	if err != nil {
+		return inputError(err, "Typst input rejected: ")
+	}
+	return nil
+}
+
+// checkAssets validates the names and total size of uploaded assets
+func (s *PDFService) checkAssets(assets map[string][]byte) *conversionError {
+	var total int64
+	for name, data := range assets {
+		if err := checkSandboxPath(name); err != nil {
+			return &conversionError{http.StatusBadRequest, outcomeRejected, "Invalid asset name: " + err.Error()}
+		}
+		total += int64(len(data))
+	}
+	if total > s.config.MaxFileSize {
+		return &conversionError{http.StatusBadRequest, outcomeRejected, "Assets exceed maximum file size limit"}
+	}
+	return nil
+}
+
+// compileTypst runs a tracked conversion job turning Typst content into a PDF.
+// It applies quotas, the result cache, the worker pool, the conversion timeout
+// and the output limits, and returns the PDF with its page count, compile time,
+// template and warnings.
+// The compiler can only read files from a per-job root holding the template
+// directory and assets.
+func (s *PDFService) compileTypst(parent context.Context, reqID string, key *keyState, typstContent, template string, assets map[string][]byte) (*mdpdf.ConvertResult, *conversionError) {
+	if cerr := s.checkAssets(assets); cerr != nil {
+		s.metrics.Conversions.Inc(outcomeRejected, template)
+		return nil, cerr
+	}
+	if template == rawTemplate {
+		if cerr := s.checkRawTypst(key, typstContent); cerr != nil {
+			s.metrics.Conversions.Inc(outcomeRejected, template)
+			return nil, cerr
+		}
+		if err := checkSandboxPaths(typstContent); err != nil {
+			s.metrics.Conversions.Inc(outcomeRejected, template)
+			return nil, &conversionError{http.StatusBadRequest, outcomeRejected, "File access denied: " + err.Error()}
+		}
+	}
+
+	if key != nil {
+		if err := key.checkQuota(time.Now()); err != nil {
+			s.metrics.Conversions.Inc(outcomeRejected, template)
+			return nil, &conversionError{http.StatusTooManyRequests, outcomeRejected, "Quota exceeded: " + err.Error()}
+		}
+	}
+
+	// Create conversion job for tracking
+	jobID := generateJobID()
+	ctx, cancel := context.WithTimeout(parent, s.config.TimeoutDuration)
+	defer cancel()
+
+	job := &ConversionJob{
+		ID:        jobID,
+		RequestID: reqID,
+		Template:  template,
+		StartTime: time.Now(),
+		Context:   ctx,
+		Cancel:    cancel,
+	}
+	logger := slog.With("request_id", reqID, "job_id", jobID, "template", template)
+
+	// Register job
+	s.activeJobsMux.Lock()
+	s.activeJobs[jobID] = job
+	s.activeJobsMux.Unlock()
+
+	// Cleanup job on completion
+	defer func() {
+		s.activeJobsMux.Lock()
+		delete(s.activeJobs, jobID)
+		s.activeJobsMux.Unlock()
+	}()
+
+	s.metrics.InputSize.Observe(float64(len(typstContent)), template)
+
+	// Serve repeated documents from the result cache. The key includes the
+	// template so a hit reports the template of this request.
+	templateHash := s.templateHash(template)
+	resultKey := cacheKey(template, templateHash, typstContent, assets)
+	result, cached := s.cache.Get(resultKey)
+
+	if cached {
+		logger.Info("pdf served from cache", "input_bytes", len(typstContent), "output_bytes", len(result.PDF))
+		reportProgress(ctx, JobEvent{Event: eventCompileFinished, Cached: true})
+	} else {
+		// Wait for a free compile slot
+		if err := s.pool.Acquire(ctx, queuePositionObserver(ctx)); err != nil {
+			s.metrics.Conversions.Inc(outcomeTimeout, template)
+			logger.Warn("timed out waiting for a compile slot", "queue_depth", s.pool.QueueDepth())
+			return nil, &conversionError{http.StatusServiceUnavailable, outcomeTimeout, "Server busy, timed out waiting for a compile slot"}
+		}
+
+		logger.Info("starting typst conversion", "input_bytes", len(typstContent))
+		reportProgress(ctx, JobEvent{Event: eventCompileStarted})
+
+		// Compile in a sandboxed job root. On cancellation the compiler is
+		// killed in the background, which then releases the slot.
+		type compileResult struct {
+			result *mdpdf.ConvertResult
+			err    error
+		}
+
+		startTime := time.Now()
+		resultChan := make(chan compileResult, 1)
+		go func() {
+			defer s.pool.Release()
+			result, err := s.compiler.Compile(ctx, typstContent, assets)
+			resultChan <- compileResult{result: result, err: err}
+		}()
+
+		select {
+		case <-ctx.Done():
+			s.metrics.Conversions.Inc(outcomeTimeout, template)
+			logger.Warn("typst conversion cancelled", "compile_ms", time.Since(startTime).Milliseconds(), "error", ctx.Err().Error())
+			return nil, &conversionError{http.StatusServiceUnavailable, outcomeTimeout, "Conversion cancelled: " + ctx.Err().Error()}
+		case res := <-resultChan:
+			duration := time.Since(startTime)
+			s.metrics.CompileDuration.Observe(duration.Seconds(), template)
+			reportProgress(ctx, JobEvent{Event: eventCompileFinished, DurationMs: duration.Milliseconds()})
+			var pdfBytes []byte
+			if res.err == nil {
+				pdfBytes = res.result.PDF
+			}
+			if cerr := s.checkCompileResult(logger, template, typstContent, pdfBytes, duration, res.err); cerr != nil {
+				return nil, cerr
+			}
+			pages, cerr := s.checkOutputLimits(logger, template, pdfBytes)
+			if cerr != nil {
+				return nil, cerr
+			}
+			result = res.result
+			result.Pages = pages
+			result.CompileDuration = duration
+			result.TemplateName = template
+			result.TemplateHash = templateHash
+		}
+		s.cache.Add(resultKey, result)
+	}
+
+	s.metrics.Conversions.Inc(outcomeSuccess, template)
+	s.metrics.PDFSize.Observe(float64(len(result.PDF)), template)
+
+	if key != nil {
+		key.recordConversion(time.Now(), len(result.PDF))
+	}
+
+	return result, nil
+}
+
+// checkCompileResult logs and records the outcome of a compilation and reports
+// an error when it did not produce a PDF
+func (s *PDFService) checkCompileResult(logger *slog.Logger, template, typstContent string, pdfBytes []byte, duration time.Duration, err error) *conversionError {
+	if err != nil {
+		s.metrics.Conversions.Inc(outcomeCompileError, template)
+		logger.Error("typst compilation failed",
+			"input_bytes", len(typstContent),
+			"compile_ms", duration.Milliseconds(),
+			"error", err.Error(),
+		)
+		return &conversionError{http.StatusInternalServerError, outcomeCompileError, "Typst compilation failed: " + err.Error()}
+	}
+
+	if len(pdfBytes) == 0 {
+		s.metrics.Conversions.Inc(outcomeEmptyOutput, template)
+		logger.Error("generated PDF is empty", "compile_ms", duration.Milliseconds())
+		return &conversionError{http.StatusInternalServerError, outcomeEmptyOutput, "Generated PDF is empty"}
+	}
+
+	logger.Info("pdf generated",
+		"input_bytes", len(typstContent),
+		"output_bytes", len(pdfBytes),
+		"compile_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// checkOutputLimits rejects PDFs with more than MaxPages pages or MaxOutputBytes
+// bytes. It returns the page count.
+func (s *PDFService) checkOutputLimits(logger *slog.Logger, template string, pdfBytes []byte) (int, *conversionError) {
+	pages, err := mdpdf.CheckOutputLimits(pdfBytes, s.config.MaxPages, s.config.MaxOutputBytes)
+	var limitErr *mdpdf.LimitError
+	if errors.As(err, &limitErr) {
+		s.metrics.Conversions.Inc(outcomeTooLarge, template)
+		logger.Warn("generated PDF exceeds output limit", "limit", limitErr.Limit, "max", limitErr.Max, "actual", limitErr.Actual)
+		return 0, &conversionError{http.StatusUnprocessableEntity, outcomeTooLarge, "Output limit exceeded: " + err.Error()}
+	}
+	return pages, nil
+}
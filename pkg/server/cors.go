@@ -0,0 +1,99 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultCORSMethods and defaultCORSHeaders are used when no explicit list is configured
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	defaultCORSHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"}
+)
+
+// corsExposedHeaders are the response headers cross-origin clients may read
+var corsExposedHeaders = []string{
+	"Content-Disposition",
+	requestIDHeader,
+	pageCountHeader,
+	compileTimeHeader,
+	templateHeader,
+	templateHashHeader,
+	cacheHeader,
+	warningCountHeader,
+	warningHeader,
+}
+
+// newCORSMiddleware builds the CORS middleware from the configuration. It returns a nil
+// handler when cross-origin requests are disabled (same-origin only) together with a
+// human readable description of the effective policy.
+func newCORSMiddleware(config *Config) (gin.HandlerFunc, string, error) {
+	origins := config.CORSAllowedOrigins
+	if origins == nil {
+		// Development keeps the historical allow-all behaviour, production is same-origin only
+		if gin.Mode() == gin.ReleaseMode {
+			origins = []string{}
+		} else {
+			origins = []string{"*"}
+		}
+	}
+
+	if len(origins) == 0 {
+		return nil, "same-origin only (no cross-origin requests allowed)", nil
+	}
+
+	corsConfig := cors.Config{
+		AllowMethods:     config.CORSAllowedMethods,
+		AllowHeaders:     config.CORSAllowedHeaders,
+		ExposeHeaders:    corsExposedHeaders,
+		AllowCredentials: config.CORSAllowCredentials,
+		MaxAge:           config.CORSMaxAge,
+	}
+	if len(corsConfig.AllowMethods) == 0 {
+		corsConfig.AllowMethods = defaultCORSMethods
+	}
+	if len(corsConfig.AllowHeaders) == 0 {
+		corsConfig.AllowHeaders = defaultCORSHeaders
+	}
+
+	for _, origin := range origins {
+		if origin == "*" {
+			corsConfig.AllowAllOrigins = true
+		}
+	}
+	if corsConfig.AllowAllOrigins {
+		if corsConfig.AllowCredentials {
+			return nil, "", errors.New("CORS: credentials cannot be allowed for all origins (\"*\")")
+		}
+	} else {
+		corsConfig.AllowOrigins = origins
+	}
+
+	if err := corsConfig.Validate(); err != nil {
+		return nil, "", fmt.Errorf("CORS: %w", err)
+	}
+
+	description := fmt.Sprintf("origins=%s methods=%s headers=%s credentials=%t max-age=%v",
+		strings.Join(origins, ","),
+		strings.Join(corsConfig.AllowMethods, ","),
+		strings.Join(corsConfig.AllowHeaders, ","),
+		corsConfig.AllowCredentials,
+		corsConfig.MaxAge)
+
+	return cors.New(corsConfig), description, nil
+}
+
+// splitList splits a comma separated setting into trimmed, non-empty items
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
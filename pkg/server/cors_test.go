@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsRequest sends a GET request from origin through the CORS middleware
+func corsRequest(handler gin.HandlerFunc, origin string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.Use(handler)
+	r.GET("/health", func(c *gin.Context) {
+		c.Header(pageCountHeader, "1")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("Origin", origin)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSReleaseModeDefault(t *testing.T) {
+	mode := gin.Mode()
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(mode)
+
+	handler, policy, err := newCORSMiddleware(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handler != nil || !strings.Contains(policy, "same-origin") {
+		t.Fatalf("Expected same-origin only policy in release mode, got %q", policy)
+	}
+}
+
+func TestCORSAllowlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.CORSAllowedOrigins = []string{"https://app.example.com"}
+
+	handler, _, err := newCORSMiddleware(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := corsRequest(handler, "https://app.example.com")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Fatalf("Expected allowed origin to be echoed, got %q", got)
+	}
+	exposed := w.Header().Get("Access-Control-Expose-Headers")
+	for _, header := range []string{pageCountHeader, templateHashHeader, requestIDHeader} {
+		if !strings.Contains(strings.ToLower(exposed), strings.ToLower(header)) {
+			t.Errorf("Expected %s to be exposed, got %q", header, exposed)
+		}
+	}
+
+	w = corsRequest(handler, "https://evil.example.com")
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("Expected other origins to be rejected, got %d with %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSRejectsCredentialsForAllOrigins(t *testing.T) {
+	config := DefaultConfig()
+	config.CORSAllowedOrigins = []string{"*"}
+	config.CORSAllowCredentials = true
+
+	if _, _, err := newCORSMiddleware(config); err == nil {
+		t.Fatal("Expected credentials with \"*\" to be rejected")
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Progress events of an async job, in the order they occur
+const (
+	eventQueued          = "queued"
+	eventTemplateLoaded  = "template_loaded"
+	eventCompileStarted  = "compile_started"
+	eventCompileFinished = "compile_finished"
+	eventResultReady     = "result_ready"
+	eventFailed          = "failed"
+)
+
+// sseKeepAliveInterval is how often an idle event stream sends a comment to keep proxies from closing it
+const sseKeepAliveInterval = 15 * time.Second
+
+// JobEvent is a progress event of an async job, streamed by GET /api/jobs/:id/events
+type JobEvent struct {
+	Event       string `json:"event"`
+	JobID       string `json:"jobId"`
+	Position    int    `json:"position,omitempty"`    // queue position while queued
+	Template    string `json:"template,omitempty"`    // template_loaded
+	Cached      bool   `json:"cached,omitempty"`      // compile_finished served from the result cache
+	DurationMs  int64  `json:"durationMs,omitempty"`  // compile_finished
+	Error       string `json:"error,omitempty"`       // failed
+	DownloadURL string `json:"downloadUrl,omitempty"` // result_ready
+	Timestamp   string `json:"timestamp"`
+}
+
+// final reports whether no further events follow this one
+func (e JobEvent) final() bool {
+	return e.Event == eventResultReady || e.Event == eventFailed
+}
+
+// progressKey is the context key of the conversion progress observer
+type progressKey struct{}
+
+// withProgress returns a context whose conversions report their progress to fn.
+// fn must not block.
+func withProgress(ctx context.Context, fn func(JobEvent)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress sends an event to the progress observer of ctx, if any
+func reportProgress(ctx context.Context, event JobEvent) {
+	if fn, ok := ctx.Value(progressKey{}).(func(JobEvent)); ok {
+		fn(event)
+	}
+}
+
+// queuePositionObserver returns a pool position callback reporting "queued" events,
+// or nil when ctx has no progress observer
+func queuePositionObserver(ctx context.Context) func(int) {
+	if _, ok := ctx.Value(progressKey{}).(func(JobEvent)); !ok {
+		return nil
+	}
+	return func(position int) {
+		reportProgress(ctx, JobEvent{Event: eventQueued, Position: position})
+	}
+}
+
+// JobEventsHandler streams the progress of an async job as Server-Sent Events.
+// Past events are replayed first (after Last-Event-ID when reconnecting), and the
+// stream ends after the result_ready or failed event.
+func (s *PDFService) JobEventsHandler(c *gin.Context) {
+	job, ok := s.lookupJob(c)
+	if !ok {
+		return
+	}
+
+	notify, unsubscribe := s.jobs.Subscribe(job.ID)
+	defer unsubscribe()
+
+	next := 0
+	if lastID, err := strconv.Atoi(c.GetHeader("Last-Event-ID")); err == nil && lastID >= 0 {
+		next = lastID + 1
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		events, done := s.jobs.Events(job.ID, next)
+		for _, event := range events {
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", next, event.Event, data)
+			next++
+		}
+		c.Writer.Flush()
+
+		if done {
+			return
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-notify:
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+		}
+	}
+}
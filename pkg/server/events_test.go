@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobEventStream(t *testing.T) {
+	_, r := newTestService(t)
+
+	w := postJSON(t, r, "/api/jobs", AsyncJobRequest{ConvertRequest: ConvertRequest{TypstContent: "= Streamed"}})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var job AsyncJob
+	json.Unmarshal(w.Body.Bytes(), &job)
+
+	// The stream ends after the final event
+	stream := httptest.NewRecorder()
+	r.ServeHTTP(stream, httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.ID+"/events", nil))
+	if ct := stream.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", ct)
+	}
+
+	var events []string
+	scanner := bufio.NewScanner(stream.Body)
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			events = append(events, name)
+		}
+	}
+
+	expected := []string{eventQueued, eventTemplateLoaded, eventCompileStarted, eventCompileFinished, eventResultReady}
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected events %v, got %v", expected, events)
+	}
+
+	// Reconnecting after the final event replays nothing and returns immediately
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.ID+"/events", nil)
+	req.Header.Set("Last-Event-ID", "4")
+	replay := httptest.NewRecorder()
+	r.ServeHTTP(replay, req)
+	if strings.Contains(replay.Body.String(), "event:") {
+		t.Fatalf("Expected no replayed events, got %q", replay.Body.String())
+	}
+}
+
+func TestQueuePositionUpdates(t *testing.T) {
+	pool := newWorkerPool(1)
+	if err := pool.Acquire(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	positions := make(chan int, 10)
+	acquired := make(chan struct{})
+	go func() {
+		pool.Acquire(context.Background(), nil)
+		close(acquired)
+	}()
+	waitFor(t, func() bool { return pool.QueueDepth() == 1 })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pool.Acquire(ctx, func(position int) { positions <- position })
+
+	if got := <-positions; got != 2 {
+		t.Fatalf("Expected initial position 2, got %d", got)
+	}
+
+	pool.Release()
+	<-acquired
+	if got := <-positions; got != 1 {
+		t.Fatalf("Expected position 1 after the first waiter started, got %d", got)
+	}
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// selfTestMarkdown is compiled with the skeleton template by the background self-test
+const selfTestMarkdown = `# Health Check
+
+This is a test document to verify Typst compilation.`
+
+// packageImportPattern matches Typst package imports such as "@preview/cmarker:0.1.1"
+var packageImportPattern = regexp.MustCompile(`"@([a-z0-9_-]+)/([A-Za-z0-9_-]+):([0-9]+\.[0-9]+\.[0-9]+)"`)
+
+// SelfTestResult is the outcome of the last background compilation self-test
+type SelfTestResult struct {
+	OK         bool   `json:"ok"`
+	Message    string `json:"message"`
+	DurationMs int64  `json:"durationMs"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// ReadinessCheck is the result of a single readiness condition
+type ReadinessCheck struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// ReadinessResponse represents the /readyz response
+type ReadinessResponse struct {
+	Status    string                    `json:"status"`
+	Checks    map[string]ReadinessCheck `json:"checks"`
+	Timestamp string                    `json:"timestamp"`
+}
+
+// StartSelfTest runs the compilation self-test now and then every interval until ctx is done
+func (s *PDFService) StartSelfTest(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.runSelfTest()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// runSelfTest compiles the skeleton template with sample content and records the result.
+// Compiling the real template also fetches its packages into the Typst cache.
+func (s *PDFService) runSelfTest() {
+	result := &SelfTestResult{Timestamp: time.Now().Format(time.RFC3339)}
+
+	startTime := time.Now()
+	typstContent, err := s.renderSkeleton(selfTestMarkdown)
+	var pdfBytes []byte
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.TimeoutDuration)
+		var res *mdpdf.ConvertResult
+		if res, err = s.compiler.Compile(ctx, typstContent, nil); err == nil {
+			pdfBytes = res.PDF
+		}
+		cancel()
+	}
+	duration := time.Since(startTime)
+	result.DurationMs = duration.Milliseconds()
+
+	switch {
+	case err != nil:
+		result.Message = err.Error()
+	case len(pdfBytes) == 0:
+		result.Message = "generated PDF is empty"
+	default:
+		result.OK = true
+		result.Message = fmt.Sprintf("Test compilation successful (%d bytes in %v)", len(pdfBytes), duration)
+	}
+
+	if result.OK {
+		slog.Debug("self-test passed", "compile_ms", result.DurationMs)
+	} else {
+		slog.Warn("self-test failed", "compile_ms", result.DurationMs, "error", result.Message)
+	}
+	s.selfTest.Store(result)
+}
+
+// lastSelfTest returns the most recent self-test result, or nil before the first run
+func (s *PDFService) lastSelfTest() *SelfTestResult {
+	return s.selfTest.Load()
+}
+
+// LivenessHandler reports that the process is up. It performs no work.
+func (s *PDFService) LivenessHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+// ReadinessHandler reports whether the service can take conversion traffic
+func (s *PDFService) ReadinessHandler(c *gin.Context) {
+	checks := map[string]ReadinessCheck{
+		"template": s.checkTemplate(),
+		"packages": s.checkPackages(),
+		"compiler": checkCompiler(),
+		"fonts":    checkFonts(),
+		"queue":    s.checkQueue(),
+	}
+	if s.Draining() {
+		checks["shutdown"] = ReadinessCheck{Message: "service is shutting down"}
+	}
+
+	response := ReadinessResponse{
+		Status:    "ready",
+		Checks:    checks,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	status := http.StatusOK
+	for _, check := range checks {
+		if !check.OK {
+			response.Status = "not ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	c.JSON(status, response)
+}
+
+// checkTemplate verifies the skeleton template is readable and has a placeholder
+func (s *PDFService) checkTemplate() ReadinessCheck {
+	if _, err := s.renderSkeleton(""); err != nil {
+		return ReadinessCheck{Message: err.Error()}
+	}
+	return ReadinessCheck{OK: true}
+}
+
+// checkPackages verifies every package imported by the skeleton template is in the local Typst package store
+func (s *PDFService) checkPackages() ReadinessCheck {
+	content, err := mdpdf.ReadTemplate(s.config.SkeletonPath)
+	if err != nil {
+		return ReadinessCheck{Message: err.Error()}
+	}
+
+	var missing []string
+	for _, match := range packageImportPattern.FindAllStringSubmatch(string(content), -1) {
+		if !packageAvailable(match[1], match[2], match[3]) {
+			missing = append(missing, fmt.Sprintf("@%s/%s:%s", match[1], match[2], match[3]))
+		}
+	}
+
+	if len(missing) > 0 {
+		return ReadinessCheck{Message: "missing packages: " + strings.Join(missing, ", ")}
+	}
+	return ReadinessCheck{OK: true}
+}
+
+// checkCompiler verifies the Typst compiler is installed
+func checkCompiler() ReadinessCheck {
+	if _, err := mdpdf.TypstBinary(); err != nil {
+		return ReadinessCheck{Message: err.Error()}
+	}
+	return ReadinessCheck{OK: true}
+}
+
+// checkFonts verifies the fonts bundled with gotypst have been installed
+func checkFonts() ReadinessCheck {
+	fonts, _ := filepath.Glob(filepath.Join(mdpdf.FontDir(), "*.ttf"))
+	if len(fonts) == 0 {
+		return ReadinessCheck{Message: "no fonts found in gotypst font directory"}
+	}
+	return ReadinessCheck{OK: true}
+}
+
+// checkQueue fails when more conversions are waiting than the configured maximum
+func (s *PDFService) checkQueue() ReadinessCheck {
+	if depth := s.pool.QueueDepth(); s.config.MaxQueueDepth > 0 && depth >= s.config.MaxQueueDepth {
+		return ReadinessCheck{Message: fmt.Sprintf("queue saturated (%d waiting)", depth)}
+	}
+	return ReadinessCheck{OK: true}
+}
+
+// packageAvailable reports whether a package exists in Typst's local package or cache directories
+func packageAvailable(namespace, name, version string) bool {
+	var roots []string
+	if dir := os.Getenv("TYPST_PACKAGE_PATH"); dir != "" {
+		roots = append(roots, dir)
+	}
+	if dir := os.Getenv("TYPST_PACKAGE_CACHE_PATH"); dir != "" {
+		roots = append(roots, dir)
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		roots = append(roots, filepath.Join(dir, "typst", "packages"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dataDir := os.Getenv("XDG_DATA_HOME")
+		if dataDir == "" {
+			dataDir = filepath.Join(home, ".local", "share")
+		}
+		roots = append(roots, filepath.Join(dataDir, "typst", "packages"))
+	}
+
+	for _, root := range roots {
+		if _, err := os.Stat(filepath.Join(root, namespace, name, version, "typst.toml")); err == nil {
+			return true
+		}
+	}
+	return false
+}
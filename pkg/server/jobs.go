@@ -0,0 +1,426 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// Async job states
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+)
+
+// jobCompletedEvent is the webhook event sent when an async job finishes
+const jobCompletedEvent = "job.completed"
+
+// jobSweepInterval is how often expired jobs are dropped by the sweeper
+const jobSweepInterval = time.Minute
+
+// errJobStoreFull is returned when the job store has reached its job or byte limit
+var errJobStoreFull = errors.New("job store is full")
+
+// AsyncJobRequest represents the body of POST /api/jobs
+type AsyncJobRequest struct {
+	ConvertRequest
+	CallbackURL string `json:"callbackUrl"`
+}
+
+// JobDiagnostics describes how an async conversion went
+type JobDiagnostics struct {
+	Outcome     string `json:"outcome"`
+	InputBytes  int    `json:"inputBytes"`
+	OutputBytes int    `json:"outputBytes,omitempty"`
+	Pages       int    `json:"pages,omitempty"`
+	DurationMs  int64  `json:"durationMs"`
+	Message     string `json:"message,omitempty"`
+
+	Warnings []mdpdf.Diagnostic `json:"warnings,omitempty"`
+}
+
+// AsyncJob is a conversion running in the background
+type AsyncJob struct {
+	ID          string          `json:"id"`
+	RequestID   string          `json:"requestId"`
+	Status      string          `json:"status"`
+	Template    string          `json:"template"`
+	Filename    string          `json:"filename"`
+	CallbackURL string          `json:"callbackUrl,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Diagnostics *JobDiagnostics `json:"diagnostics,omitempty"`
+	StatusURL   string          `json:"statusUrl"`
+	DownloadURL string          `json:"downloadUrl,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	FinishedAt  *time.Time      `json:"finishedAt,omitempty"`
+
+	owner       string // name of the API key that created the job, empty when auth is disabled
+	pdf         []byte
+	events      []JobEvent
+	subscribers map[chan struct{}]struct{}
+}
+
+// jobStore keeps async jobs and their results until the retention period has passed
+type jobStore struct {
+	mu        sync.Mutex
+	jobs      map[string]*AsyncJob
+	retention time.Duration
+	maxJobs   int   // 0 = unlimited
+	maxBytes  int64 // total PDF bytes, 0 = unlimited
+}
+
+func newJobStore(retention time.Duration, maxJobs int, maxBytes int64) *jobStore {
+	return &jobStore{jobs: make(map[string]*AsyncJob), retention: retention, maxJobs: maxJobs, maxBytes: maxBytes}
+}
+
+// Add stores a new job after dropping expired ones. It returns errJobStoreFull
+// if the store holds maxJobs jobs or maxBytes of PDFs.
+func (js *jobStore) Add(job *AsyncJob) error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.sweepLocked(time.Now())
+
+	var size int64
+	for _, stored := range js.jobs {
+		size += int64(len(stored.pdf))
+	}
+	if (js.maxJobs > 0 && len(js.jobs) >= js.maxJobs) || (js.maxBytes > 0 && size >= js.maxBytes) {
+		return errJobStoreFull
+	}
+
+	js.jobs[job.ID] = job
+	return nil
+}
+
+// Sweep drops finished jobs older than the retention period
+func (js *jobStore) Sweep(now time.Time) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.sweepLocked(now)
+}
+
+func (js *jobStore) sweepLocked(now time.Time) {
+	for id, old := range js.jobs {
+		if old.FinishedAt != nil && now.Sub(*old.FinishedAt) > js.retention {
+			delete(js.jobs, id)
+		}
+	}
+}
+
+// StartJobSweeper drops expired jobs every interval until ctx is done, so their
+// PDFs are released even when no new jobs arrive
+func (s *PDFService) StartJobSweeper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.jobs.Sweep(now)
+			}
+		}
+	}()
+}
+
+// Get returns a copy of the job and its PDF
+func (js *jobStore) Get(id string) (AsyncJob, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return AsyncJob{}, false
+	}
+	return *job, true
+}
+
+// Update applies fn to the stored job and returns a copy of the result
+func (js *jobStore) Update(id string, fn func(*AsyncJob)) AsyncJob {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job := js.jobs[id]
+	fn(job)
+	return *job
+}
+
+// Publish records a progress event of the job and wakes up its subscribers
+func (js *jobStore) Publish(id string, event JobEvent) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return
+	}
+
+	event.JobID = id
+	event.Timestamp = time.Now().Format(time.RFC3339Nano)
+	job.events = append(job.events, event)
+
+	for notify := range job.subscribers {
+		select {
+		case notify <- struct{}{}:
+		default: // a wake-up is already pending
+		}
+	}
+}
+
+// Events returns the job's events starting at index from, and whether the job has
+// published its final event
+func (js *jobStore) Events(id string, from int) ([]JobEvent, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return nil, true
+	}
+
+	done := len(job.events) > 0 && job.events[len(job.events)-1].final()
+	if from >= len(job.events) {
+		return nil, done
+	}
+	return append([]JobEvent(nil), job.events[from:]...), done
+}
+
+// Subscribe returns a channel that is signalled when the job publishes events,
+// and a function to unsubscribe
+func (js *jobStore) Subscribe(id string) (<-chan struct{}, func()) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	notify := make(chan struct{}, 1)
+	job, ok := js.jobs[id]
+	if !ok {
+		return notify, func() {}
+	}
+	if job.subscribers == nil {
+		job.subscribers = make(map[chan struct{}]struct{})
+	}
+	job.subscribers[notify] = struct{}{}
+
+	return notify, func() {
+		js.mu.Lock()
+		delete(job.subscribers, notify)
+		js.mu.Unlock()
+	}
+}
+
+// CreateJobHandler validates a conversion request and runs it in the background.
+// It responds with 202 and the job, which can be polled or reported by webhook.
+func (s *PDFService) CreateJobHandler(c *gin.Context) {
+	var req AsyncJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(c, "Invalid request format: "+err.Error()))
+		return
+	}
+
+	if req.CallbackURL != "" {
+		if !s.webhooks.Enabled() {
+			c.JSON(http.StatusBadRequest, errorBody(c, "Callbacks are not enabled on this server"))
+			return
+		}
+		u, err := url.Parse(req.CallbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.JSON(http.StatusBadRequest, errorBody(c, "callbackUrl must be an absolute http(s) URL"))
+			return
+		}
+		if err := s.webhooks.checkCallbackHost(u.Hostname()); err != nil {
+			c.JSON(http.StatusBadRequest, errorBody(c, "callbackUrl must point to a public address"))
+			return
+		}
+	}
+
+	key := requestKey(c)
+	var typstContent, template string
+	if markdownContent := req.markdown(); markdownContent != "" {
+		var cerr *conversionError
+		typstContent, template, cerr = s.renderMarkdown(key, markdownContent, req.Template)
+		if cerr != nil {
+			c.JSON(cerr.Status, errorBody(c, cerr.Message))
+			return
+		}
+	} else if req.TypstContent != "" {
+		if int64(len(req.TypstContent)) > s.config.MaxFileSize {
+			c.JSON(http.StatusBadRequest, errorBody(c, "Content exceeds maximum file size limit"))
+			return
+		}
+		if cerr := s.checkRawTypst(key, req.TypstContent); cerr != nil {
+			c.JSON(cerr.Status, errorBody(c, cerr.Message))
+			return
+		}
+		typstContent, template = req.TypstContent, rawTemplate
+	} else {
+		c.JSON(http.StatusBadRequest, errorBody(c, "Missing markdownContent, parts or typstContent in request body"))
+		return
+	}
+
+	id := generateJobID()
+	job := &AsyncJob{
+		ID:          id,
+		RequestID:   requestID(c),
+		Status:      jobQueued,
+		Template:    template,
+		Filename:    pdfFilename(req.Options, "document.pdf"),
+		CallbackURL: req.CallbackURL,
+		StatusURL:   s.publicURL(c, "/api/jobs/"+id),
+		CreatedAt:   time.Now(),
+	}
+	if key != nil {
+		job.owner = key.key.Name
+	}
+	response := *job
+	if err := s.jobs.Add(job); err != nil {
+		requestLogger(c).Warn("async job rejected", "error", err.Error())
+		c.Header("Retry-After", "60")
+		c.JSON(http.StatusServiceUnavailable, errorBody(c, "Too many stored jobs, try again later"))
+		return
+	}
+	s.jobs.Publish(id, JobEvent{Event: eventQueued})
+	s.jobs.Publish(id, JobEvent{Event: eventTemplateLoaded, Template: template})
+
+	downloadURL := s.publicURL(c, "/api/jobs/"+id+"/pdf")
+	s.background.Add(1)
+	go func() {
+		defer s.background.Add(-1)
+		s.runAsyncJob(id, key, typstContent, req.Assets, downloadURL)
+	}()
+
+	requestLogger(c).Info("async job created", "job_id", id, "template", template, "callback", req.CallbackURL != "")
+
+	c.Header("Location", response.StatusURL)
+	c.JSON(http.StatusAccepted, response)
+}
+
+// runAsyncJob compiles an async job, stores its result and delivers its callback
+func (s *PDFService) runAsyncJob(id string, key *keyState, typstContent string, assets map[string][]byte, downloadURL string) {
+	job := s.jobs.Update(id, func(job *AsyncJob) { job.Status = jobRunning })
+
+	ctx := withProgress(context.Background(), func(event JobEvent) { s.jobs.Publish(id, event) })
+	startTime := time.Now()
+	result, cerr := s.compileTypst(ctx, job.RequestID, key, typstContent, job.Template, assets)
+	finishedAt := time.Now()
+
+	diagnostics := &JobDiagnostics{
+		Outcome:    outcomeSuccess,
+		InputBytes: len(typstContent),
+		DurationMs: finishedAt.Sub(startTime).Milliseconds(),
+	}
+	var pdfBytes []byte
+	if cerr != nil {
+		diagnostics.Outcome = cerr.Outcome
+		diagnostics.Message = cerr.Message
+	} else {
+		pdfBytes = result.PDF
+		diagnostics.OutputBytes = len(pdfBytes)
+		diagnostics.Pages = result.Pages
+		diagnostics.Warnings = result.Warnings
+	}
+
+	job = s.jobs.Update(id, func(job *AsyncJob) {
+		job.FinishedAt = &finishedAt
+		job.Diagnostics = diagnostics
+		if cerr != nil {
+			job.Status = jobFailed
+			job.Error = cerr.Message
+			return
+		}
+		job.Status = jobSucceeded
+		job.DownloadURL = downloadURL
+		job.pdf = pdfBytes
+	})
+
+	if cerr != nil {
+		s.jobs.Publish(id, JobEvent{Event: eventFailed, Error: cerr.Message})
+	} else {
+		s.jobs.Publish(id, JobEvent{Event: eventResultReady, DownloadURL: downloadURL})
+	}
+
+	if job.CallbackURL != "" {
+		s.webhooks.Send(context.Background(), job.CallbackURL, WebhookPayload{
+			Event:       jobCompletedEvent,
+			JobID:       job.ID,
+			RequestID:   job.RequestID,
+			Status:      job.Status,
+			Template:    job.Template,
+			Error:       job.Error,
+			Diagnostics: job.Diagnostics,
+			DownloadURL: job.DownloadURL,
+			Timestamp:   finishedAt.Format(time.RFC3339),
+		})
+	}
+}
+
+// lookupJob returns the job named in the URL if it exists and belongs to the caller
+func (s *PDFService) lookupJob(c *gin.Context) (AsyncJob, bool) {
+	job, ok := s.jobs.Get(c.Param("id"))
+	if ok {
+		if key := requestKey(c); key != nil && !key.key.Admin && key.key.Name != job.owner {
+			ok = false
+		}
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, errorBody(c, "Job not found"))
+	}
+	return job, ok
+}
+
+// JobStatusHandler returns the state of an async job
+func (s *PDFService) JobStatusHandler(c *gin.Context) {
+	if job, ok := s.lookupJob(c); ok {
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// JobResultHandler sends the PDF of a finished async job
+func (s *PDFService) JobResultHandler(c *gin.Context) {
+	job, ok := s.lookupJob(c)
+	if !ok {
+		return
+	}
+
+	if job.Status != jobSucceeded {
+		body := errorBody(c, fmt.Sprintf("Job is %s", job.Status))
+		body["status"] = job.Status
+		if job.Error != "" {
+			body["jobError"] = job.Error
+		}
+		c.JSON(http.StatusConflict, body)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, job.Filename))
+	if job.Diagnostics != nil {
+		c.Header(pageCountHeader, strconv.Itoa(job.Diagnostics.Pages))
+	}
+	c.Data(http.StatusOK, "application/pdf", job.pdf)
+}
+
+// publicURL returns the absolute URL of path, based on PUBLIC_URL or the request host
+func (s *PDFService) publicURL(c *gin.Context, path string) string {
+	if s.config.PublicURL != "" {
+		return strings.TrimSuffix(s.config.PublicURL, "/") + path
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + path
+}
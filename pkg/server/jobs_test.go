@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// callbackDelivery is one request received by the test webhook receiver
+type callbackDelivery struct {
+	body      []byte
+	signature string
+}
+
+func TestAsyncJobCallback(t *testing.T) {
+	_, r := newTestService(t)
+
+	// The receiver fails the first delivery to exercise the retry
+	var attempts atomic.Int32
+	deliveries := make(chan callbackDelivery, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		deliveries <- callbackDelivery{body: body, signature: req.Header.Get(webhookSignatureHeader)}
+	}))
+	defer receiver.Close()
+
+	w := postJSON(t, r, "/api/jobs", AsyncJobRequest{
+		ConvertRequest: ConvertRequest{TypstContent: "= Async"},
+		CallbackURL:    receiver.URL,
+	})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var delivery callbackDelivery
+	select {
+	case delivery = <-deliveries:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Callback was not delivered")
+	}
+
+	if attempts.Load() != 2 {
+		t.Errorf("Expected 2 delivery attempts, got %d", attempts.Load())
+	}
+	if expected := SignWebhook([]byte("test-secret"), delivery.body); delivery.signature != expected {
+		t.Errorf("Expected signature %s, got %s", expected, delivery.signature)
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(delivery.body, &payload); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	if payload.Event != jobCompletedEvent || payload.Status != jobSucceeded || payload.Diagnostics == nil {
+		t.Fatalf("Unexpected payload: %+v", payload)
+	}
+
+	// The download URL serves the PDF
+	download, err := url.Parse(payload.DownloadURL)
+	if err != nil {
+		t.Fatalf("Invalid download URL %q: %v", payload.DownloadURL, err)
+	}
+	pdf := httptest.NewRecorder()
+	r.ServeHTTP(pdf, httptest.NewRequest(http.MethodGet, download.Path, nil))
+	if pdf.Code != http.StatusOK || !bytes.HasPrefix(pdf.Body.Bytes(), []byte("%PDF")) {
+		t.Fatalf("Expected PDF download, got %d: %s", pdf.Code, pdf.Body.String())
+	}
+}
+
+func TestWebhookPermanentFailure(t *testing.T) {
+	var attempts atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer receiver.Close()
+
+	sender := &webhookSender{client: receiver.Client(), secret: []byte("secret"), maxAttempts: 5, backoff: time.Millisecond}
+	if err := sender.Send(context.Background(), receiver.URL, WebhookPayload{JobID: "job"}); err == nil {
+		t.Fatal("Expected delivery error")
+	}
+	if attempts.Load() != 1 {
+		t.Fatalf("Expected no retries after a 4xx response, got %d attempts", attempts.Load())
+	}
+}
+
+func TestCallbackRejectsPrivateAddresses(t *testing.T) {
+	s, r := newTestService(t)
+	s.webhooks.allowPrivate = false
+
+	for _, callback := range []string{"http://169.254.169.254/latest", "http://127.0.0.1:8080/hook", "http://10.0.0.1/hook", "http://localhost/hook"} {
+		w := postJSON(t, r, "/api/jobs", AsyncJobRequest{
+			ConvertRequest: ConvertRequest{TypstContent: "= Async"},
+			CallbackURL:    callback,
+		})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d: %s", callback, w.Code, w.Body.String())
+		}
+	}
+
+	// Host names are checked on the resolved address when dialling
+	var attempts atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts.Add(1)
+	}))
+	defer receiver.Close()
+
+	sender := &webhookSender{client: &http.Client{Transport: publicOnlyTransport()}, secret: []byte("secret"), maxAttempts: 5, backoff: time.Millisecond}
+	err := sender.Send(context.Background(), receiver.URL, WebhookPayload{JobID: "job"})
+	if !errors.Is(err, errPrivateCallback) {
+		t.Fatalf("Expected errPrivateCallback, got %v", err)
+	}
+	if attempts.Load() != 0 {
+		t.Fatalf("Expected the private receiver not to be contacted, got %d requests", attempts.Load())
+	}
+}
+
+func TestJobStoreLimits(t *testing.T) {
+	js := newJobStore(time.Hour, 2, 0)
+	if err := js.Add(&AsyncJob{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := js.Add(&AsyncJob{ID: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := js.Add(&AsyncJob{ID: "c"}); !errors.Is(err, errJobStoreFull) {
+		t.Fatalf("Expected errJobStoreFull beyond max jobs, got %v", err)
+	}
+
+	// The sweeper releases expired jobs without waiting for new ones
+	finished := time.Now()
+	js.Update("a", func(job *AsyncJob) { job.FinishedAt = &finished })
+	js.Sweep(finished.Add(2 * time.Hour))
+	if _, ok := js.Get("a"); ok {
+		t.Fatal("Expected expired job to be swept")
+	}
+	if err := js.Add(&AsyncJob{ID: "c"}); err != nil {
+		t.Fatalf("Expected room after sweeping, got %v", err)
+	}
+
+	js = newJobStore(time.Hour, 0, 10)
+	js.Add(&AsyncJob{ID: "a", pdf: make([]byte, 10)})
+	if err := js.Add(&AsyncJob{ID: "b"}); !errors.Is(err, errJobStoreFull) {
+		t.Fatalf("Expected errJobStoreFull beyond max bytes, got %v", err)
+	}
+}
+
+func TestCreateJobWhenStoreFull(t *testing.T) {
+	s, r := newTestService(t)
+	s.jobs = newJobStore(time.Hour, 1, 0)
+
+	request := AsyncJobRequest{ConvertRequest: ConvertRequest{TypstContent: "= Async"}}
+	if w := postJSON(t, r, "/api/jobs", request); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := postJSON(t, r, "/api/jobs", request); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 when the job store is full, got %d: %s", w.Code, w.Body.String())
+	}
+	waitIdle(t, s)
+}
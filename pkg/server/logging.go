@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDContextKey is the gin context key holding the request ID
+const requestIDContextKey = "requestID"
+
+// requestIDHeader carries the request ID in requests and responses
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern limits accepted client request IDs to something safe to log and echo
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// newLogger creates the JSON structured logger for the given level name
+func newLogger(w io.Writer, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+		return nil, fmt.Errorf("invalid log level %q (use debug, info, warn or error)", level)
+	}
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl})), nil
+}
+
+// RequestIDMiddleware assigns every request an ID, taken from X-Request-ID when valid
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = generateJobID()
+		}
+
+		c.Set(requestIDContextKey, requestID)
+		c.Header(requestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// AccessLogMiddleware logs one structured line per request, replacing gin's default logger
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+
+		requestLogger(c).Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		)
+	}
+}
+
+// requestID returns the ID assigned by RequestIDMiddleware
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDContextKey)
+}
+
+// requestLogger returns the default logger annotated with the request ID
+func requestLogger(c *gin.Context) *slog.Logger {
+	if id := requestID(c); id != "" {
+		return slog.With("request_id", id)
+	}
+	return slog.Default()
+}
+
+// errorBody builds a JSON error response that carries the request ID
+func errorBody(c *gin.Context, message string) gin.H {
+	body := gin.H{"error": message}
+	if id := requestID(c); id != "" {
+		body["requestId"] = id
+	}
+	return body
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Conversion outcomes used as the "outcome" label of mdpdf_conversions_total
+const (
+	outcomeSuccess      = "success"
+	outcomeCompileError = "compile_error"
+	outcomeEmptyOutput  = "empty_output"
+	outcomeTimeout      = "timeout"
+	outcomeRejected     = "rejected"
+	outcomeTooLarge     = "too_large" // the PDF exceeded max_pages or max_output_bytes
+)
+
+// Histogram buckets
+var (
+	durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	sizeBuckets     = []float64{1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20, 50 << 20}
+)
+
+// collector is a metric family that can render itself in the Prometheus text format
+type collector interface {
+	writeTo(w io.Writer)
+}
+
+// Metrics holds the service metrics exposed on /metrics
+type Metrics struct {
+	collectors []collector
+
+	Conversions     *counterVec
+	CompileDuration *histogramVec
+	PDFSize         *histogramVec
+	InputSize       *histogramVec
+}
+
+// NewMetrics creates the metric families. Gauges are sampled from the service when scraped.
+func NewMetrics(s *PDFService) *Metrics {
+	m := &Metrics{
+		Conversions:     newCounterVec("mdpdf_conversions_total", "Conversions by outcome and template.", "outcome", "template"),
+		CompileDuration: newHistogramVec("mdpdf_compile_duration_seconds", "Typst compile duration in seconds.", durationBuckets, "template"),
+		PDFSize:         newHistogramVec("mdpdf_pdf_size_bytes", "Size of generated PDFs in bytes.", sizeBuckets, "template"),
+		InputSize:       newHistogramVec("mdpdf_input_size_bytes", "Size of conversion input in bytes.", sizeBuckets, "template"),
+	}
+
+	m.collectors = []collector{
+		m.Conversions,
+		m.CompileDuration,
+		m.PDFSize,
+		m.InputSize,
+		&valueFunc{"mdpdf_cache_hits_total", "Result cache hits.", "counter", func() float64 { return float64(s.cache.Hits()) }},
+		&valueFunc{"mdpdf_cache_misses_total", "Result cache misses.", "counter", func() float64 { return float64(s.cache.Misses()) }},
+		&valueFunc{"mdpdf_cache_hit_ratio", "Ratio of result cache hits to lookups.", "gauge", s.cache.HitRatio},
+		&valueFunc{"mdpdf_queue_depth", "Conversions waiting for a compile slot.", "gauge", func() float64 { return float64(s.pool.QueueDepth()) }},
+		&valueFunc{"mdpdf_active_jobs", "Conversions currently tracked as active.", "gauge", func() float64 { return float64(s.activeJobCount()) }},
+	}
+
+	return m
+}
+
+// Write renders all metrics in the Prometheus text exposition format
+func (m *Metrics) Write(w io.Writer) {
+	for _, c := range m.collectors {
+		c.writeTo(w)
+	}
+}
+
+// MetricsHandler serves the Prometheus metrics endpoint
+func (s *PDFService) MetricsHandler(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	s.metrics.Write(c.Writer)
+}
+
+// counterVec is a counter partitioned by label values
+type counterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+// Inc increments the counter for the given label values
+func (v *counterVec) Inc(labelValues ...string) {
+	v.mu.Lock()
+	v.values[seriesKey(labelValues)]++
+	v.mu.Unlock()
+}
+
+// Value returns the current counter value for the given label values
+func (v *counterVec) Value(labelValues ...string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.values[seriesKey(labelValues)]
+}
+
+func (v *counterVec) writeTo(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", v.name, v.help, v.name)
+	for _, key := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labels, splitSeriesKey(key), "", ""), formatFloat(v.values[key]))
+	}
+}
+
+// histogramVec is a histogram partitioned by label values
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+// Observe records a value for the given label values
+func (v *histogramVec) Observe(value float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key := seriesKey(labelValues)
+	h, ok := v.series[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(v.buckets))}
+		v.series[key] = h
+	}
+
+	for i, bound := range v.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+func (v *histogramVec) writeTo(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", v.name, v.help, v.name)
+	for _, key := range sortedKeys(v.series) {
+		h := v.series[key]
+		values := splitSeriesKey(key)
+		for i, bound := range v.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, formatLabels(v.labels, values, "le", formatFloat(bound)), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, formatLabels(v.labels, values, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.name, formatLabels(v.labels, values, "", ""), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.name, formatLabels(v.labels, values, "", ""), h.count)
+	}
+}
+
+// valueFunc is an unlabelled counter or gauge whose value is sampled at scrape time
+type valueFunc struct {
+	name, help string
+	kind       string
+	fn         func() float64
+}
+
+func (f *valueFunc) writeTo(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", f.name, f.help, f.name, f.kind, f.name, formatFloat(f.fn()))
+}
+
+// seriesKey joins label values into a map key
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func splitSeriesKey(key string) []string {
+	if key == "" {
+		return nil
+	}
+	return strings.Split(key, "\xff")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels renders {name="value",...}, optionally appending one extra label
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(value)))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
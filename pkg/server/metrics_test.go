@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	_, r := newTestService(t)
+
+	// The second identical request is served from the result cache
+	for i := 0; i < 2; i++ {
+		if w := postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{TypstContent: "= Metrics"}); w.Code != http.StatusOK {
+			t.Fatalf("Conversion failed with status %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if w := postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{TypstContent: "#undefined-function()"}); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected compile error, got status %d", w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+
+	for _, want := range []string{
+		`mdpdf_conversions_total{outcome="success",template="raw"} 2`,
+		`mdpdf_conversions_total{outcome="compile_error",template="raw"} 1`,
+		`mdpdf_compile_duration_seconds_count{template="raw"} 2`,
+		`mdpdf_pdf_size_bytes_count{template="raw"} 2`,
+		`mdpdf_input_size_bytes_bucket{template="raw",le="1024"} 3`,
+		`mdpdf_cache_hits_total 1`,
+		`mdpdf_cache_misses_total 2`,
+		`# TYPE mdpdf_queue_depth gauge`,
+		`mdpdf_queue_depth 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Metrics output missing %q\n%s", want, body)
+		}
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	got := formatLabels([]string{"template"}, []string{"a\"b\\c\nd"}, "", "")
+	want := `{template="a\"b\\c\nd"}`
+	if got != want {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+}
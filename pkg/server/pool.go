@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"sync"
+)
+
+// workerPool bounds the number of concurrent Typst compilations
+type workerPool struct {
+	slots chan struct{}
+
+	mu    sync.Mutex
+	queue []*poolWaiter // conversions waiting for a slot, in arrival order
+}
+
+// poolWaiter is a conversion waiting in the queue
+type poolWaiter struct {
+	onPosition func(position int)
+}
+
+// newWorkerPool creates a pool allowing size concurrent compilations
+func newWorkerPool(size int) *workerPool {
+	if size < 1 {
+		size = 1
+	}
+	return &workerPool{slots: make(chan struct{}, size)}
+}
+
+// Acquire waits for a free compile slot or until ctx is done. If onPosition is
+// not nil it is called with the 1-based queue position whenever it changes
+// while waiting; it must not block.
+func (p *workerPool) Acquire(ctx context.Context, onPosition func(position int)) error {
+	// Take a free slot without queueing. Waiting senders are served first by
+	// the channel, so this cannot overtake the queue.
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	w := &poolWaiter{onPosition: onPosition}
+	p.mu.Lock()
+	p.queue = append(p.queue, w)
+	w.notify(len(p.queue))
+	p.mu.Unlock()
+	defer p.leave(w)
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// leave removes w from the queue and reports the new positions of the conversions behind it
+func (p *workerPool) leave(w *poolWaiter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, other := range p.queue {
+		if other != w {
+			continue
+		}
+		p.queue = append(p.queue[:i], p.queue[i+1:]...)
+		for j := i; j < len(p.queue); j++ {
+			p.queue[j].notify(j + 1)
+		}
+		return
+	}
+}
+
+func (w *poolWaiter) notify(position int) {
+	if w.onPosition != nil {
+		w.onPosition(position)
+	}
+}
+
+// Release frees a slot obtained with Acquire
+func (p *workerPool) Release() {
+	<-p.slots
+}
+
+// QueueDepth returns the number of conversions waiting for a slot
+func (p *workerPool) QueueDepth() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue)
+}
+
+// Running returns the number of compilations in progress
+func (p *workerPool) Running() int {
+	return len(p.slots)
+}
+
+// Size returns the maximum number of concurrent compilations
+func (p *workerPool) Size() int {
+	return cap(p.slots)
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// filePathPattern matches string literals passed to Typst functions and keywords that read files
+var filePathPattern = regexp.MustCompile(`(?:\b(?:read|image|json|yaml|toml|csv|xml|cbor|plugin|bibliography)\s*\(\s*|#?\b(?:include|import)\s+)"((?:[^"\\]|\\.)*)"`)
+
+// driveLetterPattern matches Windows drive prefixes such as "C:"
+var driveLetterPattern = regexp.MustCompile(`^[A-Za-z]:`)
+
+// checkSandboxPaths rejects file paths in Typst source that are absolute or contain "..".
+// Typst resolves them inside the job root anyway; rejecting them up front gives a clear error.
+func checkSandboxPaths(typstContent string) error {
+	for _, match := range filePathPattern.FindAllStringSubmatch(typstContent, -1) {
+		if err := checkSandboxPath(match[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSandboxPath validates a file path relative to the job root
+func checkSandboxPath(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty file path")
+	}
+	if strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) || filepath.IsAbs(name) || driveLetterPattern.MatchString(name) {
+		return fmt.Errorf("absolute file path %q is not allowed", name)
+	}
+	for _, segment := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == ".." {
+			return fmt.Errorf("file path %q must not contain \"..\"", name)
+		}
+	}
+	return nil
+}
+
+// sandboxRoot is a per-job directory that Typst is confined to. It holds the
+// document, the files of the template directory and uploaded assets.
+type sandboxRoot struct {
+	dir string
+}
+
+// newSandboxRoot creates a job root below tempDir populated with the template
+// directory and the given assets
+func newSandboxRoot(tempDir, templateDir string, assets map[string][]byte) (*sandboxRoot, error) {
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(tempDir, "job-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job root: %w", err)
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
+	}
+	root := &sandboxRoot{dir: dir}
+
+	if templateDir != "" {
+		if err := root.mirror(templateDir); err != nil {
+			root.Remove()
+			return nil, err
+		}
+	}
+
+	for name, data := range assets {
+		if err := root.writeFile(name, data); err != nil {
+			root.Remove()
+			return nil, err
+		}
+	}
+
+	return root, nil
+}
+
+// mirror links (or copies) the regular files of src into the root, keeping their relative paths
+func (r *sandboxRoot) mirror(src string) error {
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil // skip directories (created on demand) and symlinks out of the template dir
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(r.dir, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if os.Link(p, dest) == nil {
+			return nil
+		}
+		return copyFile(p, dest)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to populate job root: %w", err)
+	}
+	return nil
+}
+
+// writeFile stores an uploaded asset in the root after validating its name
+func (r *sandboxRoot) writeFile(name string, data []byte) error {
+	if err := checkSandboxPath(name); err != nil {
+		return fmt.Errorf("invalid asset name: %w", err)
+	}
+
+	dest := filepath.Join(r.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to write asset %q: %w", name, err)
+	}
+	// Assets replace mirrored template files rather than writing through their hard links
+	os.Remove(dest)
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return fmt.Errorf("failed to write asset %q: %w", name, err)
+	}
+	return nil
+}
+
+// Compile compiles Typst source with file access confined to the root. The
+// result holds the PDF and the compiler warnings. When ctx is done the compiler
+// is killed.
+func (r *sandboxRoot) Compile(ctx context.Context, typstContent string) (*mdpdf.ConvertResult, error) {
+	res, err := mdpdf.RunTypst(ctx, typstContent, r.dir, nil)
+	// The service prefixes compile errors itself
+	var compileErr *mdpdf.CompileError
+	if errors.As(err, &compileErr) {
+		return nil, compileErr.Err
+	}
+	return res, err
+}
+
+// Remove deletes the root and everything in it
+func (r *sandboxRoot) Remove() {
+	os.RemoveAll(r.dir)
+}
+
+// compileSandboxed compiles Typst source in a fresh job root that is removed
+// afterwards. The source must have passed the service's converter (renderMarkdown
+// or checkRawTypst), which applies the raw Typst policy.
+func compileSandboxed(ctx context.Context, tempDir, templateDir, typstContent string, assets map[string][]byte) (*mdpdf.ConvertResult, error) {
+	root, err := newSandboxRoot(tempDir, templateDir, assets)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Remove()
+	return root.Compile(ctx, typstContent)
+}
+
+// copyFile copies a regular file
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheckSandboxPaths(t *testing.T) {
+	tests := []struct {
+		source  string
+		allowed bool
+	}{
+		{`#read("data.csv")`, true},
+		{`#image("figures/plot.png")`, true},
+		{`#import "@preview/cmarker:0.1.1"`, true},
+		{`#read("/etc/passwd")`, false},
+		{`#image( "../main.go" )`, false},
+		{`#include "chapters/../../secret.typ"`, false},
+		{`#let data = json("C:\\config.json")`, false},
+	}
+
+	for _, tt := range tests {
+		err := checkSandboxPaths(tt.source)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: expected allowed=%v, got error %v", tt.source, tt.allowed, err)
+		}
+	}
+}
+
+// TestSandboxBlocksHostFiles compiles sources that build their paths at runtime,
+// bypassing the static path check, to prove the compiler itself is confined
+func TestSandboxBlocksHostFiles(t *testing.T) {
+	servicePath, err := filepath.Abs("server.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	templatePath, err := filepath.Abs("../mdpdf/exam-template.typ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := map[string]string{
+		"absolute system file":   `#read("/etc/" + "passwd")`,
+		"parent traversal":       `#read(".." + "/" * 1 + "../" * 12 + "etc/passwd")`,
+		"absolute service file":  `#read(` + strconv.Quote(servicePath) + `)`,
+		"relative service file":  `#read("server" + ".go")`,
+		"service template":       `#read(` + strconv.Quote(templatePath) + `)`,
+		"parent of the job root": `#read("../" + "..")`,
+	}
+
+	for name, source := range sources {
+		res, err := compileSandboxed(context.Background(), t.TempDir(), "", source, nil)
+		if err == nil {
+			t.Errorf("%s: expected compile error, got %d byte PDF", name, len(res.PDF))
+			continue
+		}
+		if strings.Contains(err.Error(), "root:x:") || strings.Contains(err.Error(), "package server") {
+			t.Errorf("%s: file contents leaked into the error: %v", name, err)
+		}
+	}
+}
+
+func TestSandboxAllowsTemplateDirAndAssets(t *testing.T) {
+	templateDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(templateDir, "logo.txt"), []byte("template asset"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := `#read("logo.txt") #read("uploads/data.txt")`
+	assets := map[string][]byte{"uploads/data.txt": []byte("uploaded asset")}
+	if _, err := compileSandboxed(context.Background(), t.TempDir(), templateDir, source, assets); err != nil {
+		t.Fatalf("Expected template and asset files to be readable: %v", err)
+	}
+
+	if _, err := compileSandboxed(context.Background(), t.TempDir(), templateDir, "= Test", map[string][]byte{"../escape.txt": nil}); err == nil {
+		t.Fatal("Expected asset name with .. to be rejected")
+	}
+}
+
+func TestConvertRejectsHostPaths(t *testing.T) {
+	_, r := newTestService(t)
+
+	w := postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{TypstContent: `#read("/etc/passwd")`})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{
+		TypstContent: `#read("data.txt")`,
+		Assets:       map[string][]byte{"data.txt": []byte("hello")},
+	})
+	if w.Code != http.StatusOK || !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF")) {
+		t.Fatalf("Expected PDF using an uploaded asset, got %d: %s", w.Code, w.Body.String())
+	}
+}
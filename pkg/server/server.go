@@ -0,0 +1,206 @@
+// Package server implements the Markdown to PDF HTTP service
+package server
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-contrib/static"
+	"github.com/gin-gonic/gin"
+)
+
+// Serve loads the configuration from flags in fs (parsed from args), the
+// environment and an optional config file, then runs the HTTP service until
+// SIGINT or SIGTERM. apiOnly disables the web UI regardless of the configuration.
+// Configuration and startup errors exit the process.
+func Serve(fs *flag.FlagSet, args []string, apiOnly bool) {
+	// Command line flags
+	var hashKey = fs.String("hash-key", "", "Print the SHA-256 hash of an API key for use in API_KEYS/API_KEYS_FILE and exit")
+	var printConfig = fs.Bool("print-config", false, "Print the effective configuration as YAML and exit")
+
+	config, err := LoadConfig(fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
+	}
+
+	if *hashKey != "" {
+		fmt.Println(HashAPIKey(*hashKey))
+		return
+	}
+
+	if *printConfig {
+		if err := config.WriteYAML(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check if API-only mode is enabled via build flag or configuration
+	isApiOnly := config.APIOnly || apiOnly
+
+	// Structured JSON logging for the service and gin, configured first so
+	// startup messages use it too
+	logger, err := newLogger(os.Stdout, config.LogLevel)
+	if err != nil {
+		fatal("invalid logging configuration", err)
+	}
+	slog.SetDefault(logger)
+
+	// Initialize the PDF service
+	service, err := NewPDFService(config)
+	if err != nil {
+		fatal("failed to initialize service", err)
+	}
+
+	if service.keys.Enabled() {
+		slog.Info("API key authentication enabled", "keys", len(service.keys.keys))
+	} else {
+		slog.Warn("no API keys configured, API is unauthenticated")
+	}
+
+	// Create Gin router
+	r := gin.New()
+	r.Use(RequestIDMiddleware(), AccessLogMiddleware(), gin.Recovery())
+
+	// CORS middleware
+	corsHandler, corsPolicy, err := newCORSMiddleware(service.config)
+	if err != nil {
+		fatal("invalid CORS configuration", err)
+	}
+	if corsHandler != nil {
+		r.Use(corsHandler)
+	}
+	slog.Info("effective CORS policy", "policy", corsPolicy)
+
+	// Serve static files only if not in API-only mode
+	if !isApiOnly {
+		// Check if public directory exists
+		if _, err := os.Stat("./public"); err == nil {
+			r.Use(static.Serve("/", static.LocalFile("./public", false)))
+			slog.Info("serving static files", "dir", "./public")
+		} else {
+			slog.Warn("no public directory found, running in API-only mode")
+			isApiOnly = true
+		}
+	}
+
+	// API routes
+	api := r.Group("/api", service.DrainMiddleware(), service.AuthMiddleware())
+	{
+		api.POST("/convert-to-pdf", service.ConvertToPDFHandler)
+		api.POST("/convert-markdown-to-pdf", service.ConvertMarkdownToPDFHandler)
+		api.POST("/batch", service.BatchHandler)
+		api.POST("/jobs", service.CreateJobHandler)
+		api.GET("/jobs/:id", service.JobStatusHandler)
+		api.GET("/jobs/:id/pdf", service.JobResultHandler)
+		api.GET("/jobs/:id/events", service.JobEventsHandler)
+		api.GET("/stats", service.StatsHandler)
+		api.GET("/admin/keys", service.RequireAdmin(), service.KeyStatsHandler)
+	}
+
+	// Health checks and metrics
+	r.GET("/health", service.HealthHandler)
+	r.GET("/livez", service.LivenessHandler)
+	r.GET("/readyz", service.ReadinessHandler)
+	r.GET("/metrics", service.MetricsHandler)
+
+	// Root endpoint for API-only mode
+	if isApiOnly {
+		r.GET("/", func(c *gin.Context) {
+			c.JSON(200, gin.H{
+				"service": "Markdown to PDF Service",
+				"version": "1.0.0",
+				"mode":    "API-only",
+				"endpoints": gin.H{
+					"convert":    "POST /api/convert-to-pdf",
+					"convert-md": "POST /api/convert-markdown-to-pdf",
+					"batch":      "POST /api/batch",
+					"jobs":       "POST /api/jobs",
+					"job-status": "GET /api/jobs/:id",
+					"job-pdf":    "GET /api/jobs/:id/pdf",
+					"job-events": "GET /api/jobs/:id/events",
+					"health":     "GET /health",
+					"liveness":   "GET /livez",
+					"readiness":  "GET /readyz",
+					"metrics":    "GET /metrics",
+					"stats":      "GET /api/stats",
+					"key-stats":  "GET /api/admin/keys",
+				},
+				"docs": "https://github.com/mabixdev/GoTypstMdToPDF#api-endpoints",
+			})
+		})
+	}
+
+	port := config.Port
+
+	mode := "web"
+	if isApiOnly {
+		mode = "api-only"
+	}
+	slog.Info("Markdown to PDF Service starting", "port", port, "mode", mode, "url", "http://localhost:"+port)
+
+	// Background compilation self-test reported by /health, and removal of expired async jobs
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	service.StartSelfTest(backgroundCtx, service.config.SelfTestInterval)
+	service.StartJobSweeper(backgroundCtx, jobSweepInterval)
+
+	// Start server
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("failed to start server", err)
+		}
+	}()
+
+	// Wait for a termination signal, then drain in-flight conversions
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+
+	slog.Info("shutdown signal received, draining conversions",
+		"signal", sig.String(),
+		"active_jobs", service.activeJobCount(),
+		"grace_period", service.config.ShutdownGracePeriod.String(),
+	)
+	shutdown(srv, service)
+}
+
+// shutdown stops accepting work, waits up to the grace period for active jobs,
+// cancels whatever is left and closes the HTTP server
+func shutdown(srv *http.Server, service *PDFService) {
+	service.BeginShutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), service.config.ShutdownGracePeriod)
+	defer cancel()
+
+	if err := service.WaitForJobs(ctx); err != nil {
+		slog.Warn("grace period expired, cancelling remaining jobs", "cancelled", service.CancelJobs())
+	}
+
+	// Give handlers of cancelled jobs a moment to write their responses
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer closeCancel()
+
+	if err := srv.Shutdown(closeCtx); err != nil {
+		slog.Error("server shutdown failed", "error", err.Error())
+	}
+	service.Close()
+	slog.Info("server stopped")
+}
+
+// fatal logs an unrecoverable startup error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err.Error())
+	os.Exit(1)
+}
@@ -0,0 +1,316 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// PDFService handles PDF conversion operations
+type PDFService struct {
+	config        *Config
+	keys          *KeyStore
+	pool          *workerPool
+	cache         *resultCache
+	compiler      compiler
+	metrics       *Metrics
+	jobs          *jobStore
+	webhooks      *webhookSender
+	background    atomic.Int64 // async jobs still running or delivering callbacks
+	draining      atomic.Bool
+	selfTest      atomic.Pointer[SelfTestResult]
+	activeJobs    map[string]*ConversionJob
+	activeJobsMux sync.RWMutex
+}
+
+// ConversionJob represents an active conversion job
+type ConversionJob struct {
+	ID        string
+	RequestID string
+	Template  string
+	StartTime time.Time
+	Context   context.Context
+	Cancel    context.CancelFunc
+}
+
+// ConvertRequest represents the API request structure
+type ConvertRequest struct {
+	MarkdownContent string                 `json:"markdownContent"`
+	TypstContent    string                 `json:"typstContent"`
+	Template        string                 `json:"template"`
+	Parts           []mdpdf.Part           `json:"parts"`
+	Merge           mdpdf.MergeOptions     `json:"merge"`
+	Assets          map[string][]byte      `json:"assets"` // files readable by the document, base64 encoded
+	Options         map[string]interface{} `json:"options"`
+}
+
+// markdown returns the markdown content of the request, merging parts into one document
+func (req *ConvertRequest) markdown() string {
+	if len(req.Parts) > 0 {
+		return mdpdf.MergeMarkdown(req.Parts, req.Merge)
+	}
+	return req.MarkdownContent
+}
+
+// StatsResponse represents the stats API response
+type StatsResponse struct {
+	ActiveJobs int                      `json:"activeProcesses"`
+	Jobs       []map[string]interface{} `json:"processes"`
+}
+
+// HealthResponse represents the health check response
+type HealthResponse struct {
+	Status    string          `json:"status"`
+	Stats     StatsResponse   `json:"stats"`
+	SelfTest  *SelfTestResult `json:"selfTest,omitempty"`
+	Timestamp string          `json:"timestamp"`
+	Message   string          `json:"message,omitempty"`
+}
+
+// NewPDFService creates a new PDF service instance
+func NewPDFService(config *Config) (*PDFService, error) {
+	// Ensure temp directory exists
+	if err := os.MkdirAll(config.TempDir, 0755); err != nil {
+		slog.Warn("could not create temp directory", "path", config.TempDir, "error", err.Error())
+	}
+
+	keys, err := LoadKeyStore(config)
+	if err != nil {
+		return nil, err
+	}
+
+	compiler, err := newCompiler(config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &PDFService{
+		config:     config,
+		keys:       keys,
+		pool:       newWorkerPool(config.MaxConcurrentJobs),
+		cache:      newResultCache(config.CacheSize),
+		compiler:   compiler,
+		jobs:       newJobStore(config.JobRetention, config.MaxJobs, config.MaxJobBytes),
+		webhooks:   newWebhookSender(config),
+		activeJobs: make(map[string]*ConversionJob),
+	}
+	s.metrics = NewMetrics(s)
+
+	return s, nil
+}
+
+// ConvertToPDFHandler handles the main conversion endpoint (supports both markdown and typst)
+func (s *PDFService) ConvertToPDFHandler(c *gin.Context) {
+	var req ConvertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(c, "Invalid request format: "+err.Error()))
+		return
+	}
+
+	// Determine conversion type
+	if markdownContent := req.markdown(); markdownContent != "" {
+		s.convertMarkdownToPDF(c, markdownContent, req.Template, req.Assets, req.Options)
+	} else if req.TypstContent != "" {
+		s.convertTypstToPDF(c, req.TypstContent, rawTemplate, req.Assets, req.Options)
+	} else {
+		c.JSON(http.StatusBadRequest, errorBody(c, "Missing markdownContent, parts or typstContent in request body"))
+	}
+}
+
+// ConvertMarkdownToPDFHandler handles dedicated markdown conversion
+func (s *PDFService) ConvertMarkdownToPDFHandler(c *gin.Context) {
+	var req ConvertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(c, "Invalid request format: "+err.Error()))
+		return
+	}
+
+	markdownContent := req.markdown()
+	if markdownContent == "" {
+		c.JSON(http.StatusBadRequest, errorBody(c, "Missing markdownContent or parts in request body"))
+		return
+	}
+
+	s.convertMarkdownToPDF(c, markdownContent, req.Template, req.Assets, req.Options)
+}
+
+// convertMarkdownToPDF processes markdown using skeleton template
+func (s *PDFService) convertMarkdownToPDF(c *gin.Context, markdownContent, template string, assets map[string][]byte, options map[string]interface{}) {
+	typstContent, name, cerr := s.renderMarkdown(requestKey(c), markdownContent, template)
+	if cerr != nil {
+		c.JSON(cerr.Status, errorBody(c, cerr.Message))
+		return
+	}
+
+	requestLogger(c).Debug("starting markdown conversion", "template", name, "input_bytes", len(markdownContent))
+
+	// Convert using Typst
+	s.convertTypstToPDF(c, typstContent, name, assets, options)
+}
+
+// Response headers describing a returned PDF
+const (
+	pageCountHeader    = "X-PDF-Page-Count"
+	compileTimeHeader  = "X-PDF-Compile-Time-Ms" // 0 for cache hits
+	templateHeader     = "X-PDF-Template"
+	templateHashHeader = "X-PDF-Template-Hash"
+	cacheHeader        = "X-PDF-Cache" // "hit" or "miss"
+	warningCountHeader = "X-PDF-Warning-Count"
+	warningHeader      = "X-PDF-Warning" // one per warning, up to maxWarningHeaders
+)
+
+// maxWarningHeaders bounds the X-PDF-Warning headers of one response
+const maxWarningHeaders = 20
+
+// convertTypstToPDF converts Typst content to PDF and sends it as the response.
+// template names the template the content was built from (rawTemplate for user-supplied Typst).
+func (s *PDFService) convertTypstToPDF(c *gin.Context, typstContent, template string, assets map[string][]byte, options map[string]interface{}) {
+	result, cerr := s.compileTypst(context.Background(), requestID(c), requestKey(c), typstContent, template, assets)
+	if cerr != nil {
+		body := errorBody(c, cerr.Message)
+		if cerr.Outcome == outcomeCompileError {
+			body["timestamp"] = time.Now().Format(time.RFC3339)
+		}
+		c.JSON(cerr.Status, body)
+		return
+	}
+
+	filename := pdfFilename(options, "document.pdf")
+
+	// Set response headers
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Content-Length", fmt.Sprintf("%d", len(result.PDF)))
+	setResultHeaders(c, result)
+
+	// Send PDF data
+	c.Data(http.StatusOK, "application/pdf", result.PDF)
+}
+
+// setResultHeaders describes a conversion result in the response headers
+func setResultHeaders(c *gin.Context, result *mdpdf.ConvertResult) {
+	c.Header(pageCountHeader, strconv.Itoa(result.Pages))
+	c.Header(compileTimeHeader, strconv.FormatInt(result.CompileDuration.Milliseconds(), 10))
+	c.Header(templateHeader, result.TemplateName)
+	if result.TemplateHash != "" {
+		c.Header(templateHashHeader, result.TemplateHash)
+	}
+	if result.CacheHit {
+		c.Header(cacheHeader, "hit")
+	} else {
+		c.Header(cacheHeader, "miss")
+	}
+
+	c.Header(warningCountHeader, strconv.Itoa(len(result.Warnings)))
+	for i, warning := range result.Warnings {
+		if i == maxWarningHeaders {
+			break
+		}
+		// Header values must be single-line ASCII
+		message := strings.Trim(strconv.QuoteToASCII(warning.Message), `"`)
+		c.Writer.Header().Add(warningHeader, fmt.Sprintf("%d:%d %s", warning.Line, warning.Column, message))
+	}
+}
+
+// pdfFilename returns the "filename" option with a .pdf extension, or fallback
+func pdfFilename(options map[string]interface{}, fallback string) string {
+	filename := fallback
+	if options != nil {
+		if fn, ok := options["filename"].(string); ok && fn != "" {
+			filename = fn
+			if !strings.HasSuffix(filename, ".pdf") {
+				filename += ".pdf"
+			}
+		}
+	}
+	return filename
+}
+
+// activeJobCount returns the number of registered conversion jobs
+func (s *PDFService) activeJobCount() int {
+	s.activeJobsMux.RLock()
+	defer s.activeJobsMux.RUnlock()
+	return len(s.activeJobs)
+}
+
+// StatsHandler returns service statistics
+func (s *PDFService) StatsHandler(c *gin.Context) {
+	s.activeJobsMux.RLock()
+	defer s.activeJobsMux.RUnlock()
+
+	jobs := make([]map[string]interface{}, 0, len(s.activeJobs))
+	for id, job := range s.activeJobs {
+		jobs = append(jobs, map[string]interface{}{
+			"id":        id,
+			"requestId": job.RequestID,
+			"template":  job.Template,
+			"duration":  time.Since(job.StartTime).Milliseconds(),
+			"pid":       fmt.Sprintf("go-%s", id[:8]),
+		})
+	}
+
+	stats := StatsResponse{
+		ActiveJobs: len(s.activeJobs),
+		Jobs:       jobs,
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// HealthHandler returns service health status based on the last background self-test
+func (s *PDFService) HealthHandler(c *gin.Context) {
+	// Get current stats
+	s.activeJobsMux.RLock()
+	stats := StatsResponse{
+		ActiveJobs: len(s.activeJobs),
+		Jobs:       make([]map[string]interface{}, 0),
+	}
+	s.activeJobsMux.RUnlock()
+
+	selfTest := s.lastSelfTest()
+	response := HealthResponse{
+		Status:    "healthy",
+		Stats:     stats,
+		SelfTest:  selfTest,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	status := http.StatusOK
+	switch {
+	case s.Draining():
+		response.Status = "draining"
+		response.Message = "Service is shutting down"
+		status = http.StatusServiceUnavailable
+	case selfTest == nil:
+		response.Status = "starting"
+		response.Message = "Self-test has not completed yet"
+		status = http.StatusServiceUnavailable
+	case !selfTest.OK:
+		response.Status = "unhealthy"
+		response.Message = "Typst compilation self-test failed: " + selfTest.Message
+		status = http.StatusServiceUnavailable
+	default:
+		response.Message = selfTest.Message
+	}
+
+	c.JSON(status, response)
+}
+
+// generateJobID creates a unique job identifier
+func generateJobID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
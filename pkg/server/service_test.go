@@ -0,0 +1,246 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// newTestService creates a service with test defaults and a router exposing its endpoints
+func newTestService(t *testing.T) (*PDFService, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{
+		TempDir:           t.TempDir(),
+		SkeletonPath:      "",
+		TemplateDir:       t.TempDir(),
+		MaxFileSize:       1024 * 1024,
+		TimeoutDuration:   30 * time.Second,
+		MaxConcurrentJobs: 2,
+		CacheSize:         10,
+		MaxBatchSize:      10,
+		JobRetention:      time.Hour,
+	}
+	s := &PDFService{
+		config:     config,
+		keys:       &KeyStore{},
+		pool:       newWorkerPool(2),
+		cache:      newResultCache(10),
+		compiler:   &inProcessCompiler{tempDir: config.TempDir, templateDir: config.TemplateDir},
+		jobs:       newJobStore(time.Hour, 0, 0),
+		webhooks:   &webhookSender{client: http.DefaultClient, secret: []byte("test-secret"), maxAttempts: 3, backoff: 10 * time.Millisecond, allowPrivate: true},
+		activeJobs: make(map[string]*ConversionJob),
+	}
+	s.metrics = NewMetrics(s)
+
+	r := gin.New()
+	r.Use(RequestIDMiddleware())
+	api := r.Group("/api", s.AuthMiddleware())
+	api.POST("/convert-to-pdf", s.ConvertToPDFHandler)
+	api.POST("/batch", s.BatchHandler)
+	api.POST("/jobs", s.CreateJobHandler)
+	api.GET("/jobs/:id", s.JobStatusHandler)
+	api.GET("/jobs/:id/pdf", s.JobResultHandler)
+	api.GET("/jobs/:id/events", s.JobEventsHandler)
+	r.GET("/metrics", s.MetricsHandler)
+
+	return s, r
+}
+
+// waitIdle waits until no conversion, async job or abandoned compile is running,
+// so the test's temporary directories can be removed
+func waitIdle(t *testing.T, s *PDFService) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for s.activeJobCount() > 0 || s.background.Load() > 0 || s.pool.Running() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Service did not become idle")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// postJSON sends a JSON request to the router and returns the recorded response
+func postJSON(t *testing.T, r http.Handler, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to encode request: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestConvertTypstToPDF(t *testing.T) {
+	_, r := newTestService(t)
+
+	w := postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{TypstContent: "= Hello"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF")) {
+		t.Fatal("Response is not a PDF")
+	}
+	if w.Header().Get(requestIDHeader) == "" {
+		t.Fatal("Missing request ID header")
+	}
+}
+
+func TestConvertOutputLimits(t *testing.T) {
+	s, r := newTestService(t)
+	s.config.MaxPages = 2
+
+	w := postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{TypstContent: "One\n#pagebreak()\nTwo"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if pages := w.Header().Get(pageCountHeader); pages != "2" {
+		t.Fatalf("Expected page count header 2, got %q", pages)
+	}
+
+	w = postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{TypstContent: "#for i in range(5) { pagebreak() }"})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422 for too many pages, got %d: %s", w.Code, w.Body.String())
+	}
+
+	s.config.MaxPages = 0
+	s.config.MaxOutputBytes = 100
+	w = postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{TypstContent: "= Small"})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422 for oversized output, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRawTypstPolicy(t *testing.T) {
+	s, r := newTestService(t)
+
+	s.config.RawTypstMode = mdpdf.RawTypstAuthenticated
+	for _, path := range []string{"/api/convert-to-pdf", "/api/jobs"} {
+		w := postJSON(t, r, path, ConvertRequest{TypstContent: "= Hello"})
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected status 403 without an API key, got %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+
+	// Markdown embedding Typst through cmarker's raw-typst comments is raw Typst too
+	s.config.RawTypstMode = mdpdf.RawTypstDisabled
+	embedded := `<!--raw-typst #import "@preview/x:1.0.0": * -->`
+	for _, path := range []string{"/api/convert-to-pdf", "/api/jobs"} {
+		w := postJSON(t, r, path, ConvertRequest{MarkdownContent: embedded})
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected status 403 for embedded Typst, got %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+
+	s.config.RawTypstMode = mdpdf.RawTypstAllowed
+	s.config.RawTypstPackages = []string{"@preview/cmarker"}
+	w := postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{TypstContent: `#import "@preview/tablex:0.0.8": tablex`})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 for an unlisted package, got %d: %s", w.Code, w.Body.String())
+	}
+	w = postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{TypstContent: "= Hello"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 without imports, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestConvertMergedParts(t *testing.T) {
+	s, r := newTestService(t)
+
+	// A template without cmarker inserts the merged parts as Typst markup
+	if err := os.WriteFile(filepath.Join(s.config.TemplateDir, "plain.typ"), []byte("{{Placeholder Markdown}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{
+		Template: "plain",
+		Parts: []mdpdf.Part{
+			{Title: "Section A", Markdown: "First section"},
+			{Title: "Section B", Markdown: "Second section"},
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF")) {
+		t.Fatal("Response is not a PDF")
+	}
+}
+
+func TestConvertResultHeaders(t *testing.T) {
+	s, r := newTestService(t)
+
+	template := []byte("#set text(font: \"No Such Font\")\n{{Placeholder Markdown}}")
+	if err := os.WriteFile(filepath.Join(s.config.TemplateDir, "plain.typ"), template, 0644); err != nil {
+		t.Fatal(err)
+	}
+	req := ConvertRequest{Template: "plain", MarkdownContent: "Hello"}
+
+	w := postJSON(t, r, "/api/convert-to-pdf", req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(templateHeader); got != "plain" {
+		t.Errorf("Expected template header plain, got %q", got)
+	}
+	if got := w.Header().Get(templateHashHeader); got != mdpdf.TemplateHash(template) {
+		t.Errorf("Expected template hash %s, got %q", mdpdf.TemplateHash(template), got)
+	}
+	if got := w.Header().Get(cacheHeader); got != "miss" {
+		t.Errorf("Expected cache miss, got %q", got)
+	}
+	if got := w.Header().Get(warningCountHeader); got == "" || got == "0" {
+		t.Errorf("Expected the unknown font warning to be counted, got %q", got)
+	}
+	if len(w.Header().Values(warningHeader)) == 0 {
+		t.Error("Missing warning headers")
+	}
+
+	w = postJSON(t, r, "/api/convert-to-pdf", req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(cacheHeader); got != "hit" {
+		t.Errorf("Expected cache hit, got %q", got)
+	}
+	if got := w.Header().Get(warningCountHeader); got == "" || got == "0" {
+		t.Errorf("Expected cached result to keep its warnings, got %q", got)
+	}
+}
+
+func TestCacheKeyedOnTemplate(t *testing.T) {
+	s, r := newTestService(t)
+
+	// Identical templates render identical Typst source
+	for _, name := range []string{"plain", "other"} {
+		if err := os.WriteFile(filepath.Join(s.config.TemplateDir, name+".typ"), []byte("{{Placeholder Markdown}}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"plain", "other", "other"} {
+		w := postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{Template: name, MarkdownContent: "Hello"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if got := w.Header().Get(templateHeader); got != name {
+			t.Errorf("Expected template header %s, got %q (cache %s)", name, got, w.Header().Get(cacheHeader))
+		}
+	}
+	if hits := s.cache.Hits(); hits != 1 {
+		t.Errorf("Expected 1 cache hit, got %d", hits)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// drainPollInterval is how often WaitForJobs checks for remaining jobs
+const drainPollInterval = 100 * time.Millisecond
+
+// BeginShutdown stops the service from accepting new conversions
+func (s *PDFService) BeginShutdown() {
+	s.draining.Store(true)
+}
+
+// Draining reports whether the service is shutting down
+func (s *PDFService) Draining() bool {
+	return s.draining.Load()
+}
+
+// WaitForJobs blocks until all active jobs and async jobs, including their
+// callbacks, have finished or ctx is done
+func (s *PDFService) WaitForJobs(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for s.activeJobCount() > 0 || s.background.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// CancelJobs cancels all active jobs and returns how many were cancelled
+func (s *PDFService) CancelJobs() int {
+	s.activeJobsMux.RLock()
+	defer s.activeJobsMux.RUnlock()
+
+	for _, job := range s.activeJobs {
+		job.Cancel()
+	}
+	return len(s.activeJobs)
+}
+
+// Close stops idle compile workers. It is called once the server has stopped.
+func (s *PDFService) Close() {
+	s.compiler.Close()
+}
+
+// DrainMiddleware rejects new work while the service is shutting down
+func (s *PDFService) DrainMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.Draining() {
+			c.Header("Retry-After", "5")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, errorBody(c, "Service is shutting down"))
+			return
+		}
+		c.Next()
+	}
+}
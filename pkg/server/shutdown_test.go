@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// slowTypst takes about a second to compile
+const slowTypst = "#let x = 0\n#for i in range(3000000) { x += 1 }\n#x"
+
+// newDrainRouter returns a service whose conversion endpoint is behind DrainMiddleware
+func newDrainRouter(t *testing.T) (*PDFService, *gin.Engine) {
+	t.Helper()
+	s, _ := newTestService(t)
+	r := gin.New()
+	r.POST("/api/convert-to-pdf", s.DrainMiddleware(), s.ConvertToPDFHandler)
+	return s, r
+}
+
+// startConversion sends a slow conversion in the background and waits until it is active
+func startConversion(t *testing.T, s *PDFService, r http.Handler) <-chan *httptest.ResponseRecorder {
+	t.Helper()
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{TypstContent: slowTypst})
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.activeJobCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Conversion did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return done
+}
+
+func TestDrainCompletesInFlightRequests(t *testing.T) {
+	s, r := newDrainRouter(t)
+	done := startConversion(t, s, r)
+
+	s.BeginShutdown()
+
+	w := postJSON(t, r, "/api/convert-to-pdf", ConvertRequest{TypstContent: "= Hello"})
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected 503 with Retry-After while draining, got %d", w.Code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.WaitForJobs(ctx); err != nil {
+		t.Fatalf("WaitForJobs failed: %v", err)
+	}
+
+	if w := <-done; w.Code != http.StatusOK {
+		t.Fatalf("Expected in-flight conversion to complete, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDrainTimeoutCancelsJobs(t *testing.T) {
+	s, r := newDrainRouter(t)
+	done := startConversion(t, s, r)
+
+	s.BeginShutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.WaitForJobs(ctx); err == nil {
+		t.Fatal("Expected WaitForJobs to time out while a conversion is running")
+	}
+
+	if cancelled := s.CancelJobs(); cancelled != 1 {
+		t.Fatalf("Expected 1 cancelled job, got %d", cancelled)
+	}
+	if w := <-done; w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected cancelled conversion to fail with 503, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The in-process compile is abandoned, not interrupted
+	waitIdle(t, s)
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// defaultTemplateName returns the name of the configured skeleton template
+func (s *PDFService) defaultTemplateName() string {
+	if s.config.SkeletonPath == "" {
+		return mdpdf.DefaultTemplateName
+	}
+	return mdpdf.TemplateName(s.config.SkeletonPath)
+}
+
+// resolveTemplate maps a requested template name to its file path.
+// An empty name selects the skeleton template, whose path is empty when it is embedded.
+func (s *PDFService) resolveTemplate(name string) (string, string, error) {
+	if name == "" || name == s.defaultTemplateName() {
+		return s.defaultTemplateName(), s.config.SkeletonPath, nil
+	}
+
+	path, err := mdpdf.FindTemplate(s.config.TemplateDir, name)
+	if err != nil {
+		return "", "", err
+	}
+	return name, path, nil
+}
+
+// listTemplates returns the names of all available templates
+func (s *PDFService) listTemplates() []string {
+	var names []string
+	for _, t := range mdpdf.ListTemplates(s.config.SkeletonPath, s.config.TemplateDir) {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// templateHash returns the hash of a template's current content, or "" for raw
+// Typst and templates that cannot be read
+func (s *PDFService) templateHash(name string) string {
+	if name == rawTemplate {
+		return ""
+	}
+	_, path, err := s.resolveTemplate(name)
+	if err != nil {
+		return ""
+	}
+	content, err := mdpdf.ReadTemplate(path)
+	if err != nil {
+		return ""
+	}
+	return mdpdf.TemplateHash(content)
+}
+
+// renderSkeleton renders markdown into the skeleton template
+func (s *PDFService) renderSkeleton(markdownContent string) (string, error) {
+	converter, err := s.converter()
+	if err != nil {
+		return "", err
+	}
+	return converter.RenderMarkdown(context.Background(), markdownContent, mdpdf.WithTemplate(s.config.SkeletonPath))
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Webhook request headers
+const (
+	webhookSignatureHeader = "X-Signature-256"
+	webhookEventHeader     = "X-Webhook-Event"
+	webhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// maxWebhookBackoff caps the delay between two delivery attempts
+const maxWebhookBackoff = 5 * time.Minute
+
+// errPrivateCallback is returned for callbacks to addresses that are not public
+var errPrivateCallback = errors.New("callback address is not public")
+
+// sharedAddressSpace is the carrier-grade NAT range, which net.IP.IsPrivate does not cover
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// WebhookPayload is POSTed to a job's callback URL when the job finishes
+type WebhookPayload struct {
+	Event       string          `json:"event"`
+	JobID       string          `json:"jobId"`
+	RequestID   string          `json:"requestId"`
+	Status      string          `json:"status"`
+	Template    string          `json:"template"`
+	Error       string          `json:"error,omitempty"`
+	Diagnostics *JobDiagnostics `json:"diagnostics,omitempty"`
+	DownloadURL string          `json:"downloadUrl,omitempty"`
+	Timestamp   string          `json:"timestamp"`
+}
+
+// webhookSender delivers signed callbacks with retries and exponential backoff
+type webhookSender struct {
+	client      *http.Client
+	secret      []byte
+	maxAttempts int
+	backoff     time.Duration
+	// allowPrivate permits callbacks to non-public addresses. Otherwise the
+	// client must refuse to dial them, see publicOnlyTransport.
+	allowPrivate bool
+}
+
+func newWebhookSender(config *Config) *webhookSender {
+	client := &http.Client{Timeout: config.WebhookTimeout}
+	if !config.WebhookAllowPrivate {
+		client.Transport = publicOnlyTransport()
+	}
+	return &webhookSender{
+		client:      client,
+		secret:      []byte(config.WebhookSecret),
+		maxAttempts: config.WebhookMaxAttempts,
+		backoff:     config.WebhookBackoff,
+
+		allowPrivate: config.WebhookAllowPrivate,
+	}
+}
+
+// publicOnlyTransport returns a transport that refuses to connect to loopback,
+// private and link-local addresses. The check runs on the resolved address at
+// dial time, so a host name cannot point a callback at an internal service.
+func publicOnlyTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("%w: %s", errPrivateCallback, host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // a proxy would make the dial check useless
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// publicIP reports whether callbacks may be delivered to ip
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
+
+// checkCallbackHost rejects callback hosts that are known not to be public
+// without resolving them. Host names are checked again when they are dialled.
+func (w *webhookSender) checkCallbackHost(host string) error {
+	if w.allowPrivate {
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", errPrivateCallback, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return fmt.Errorf("%w: %s", errPrivateCallback, host)
+	}
+	return nil
+}
+
+// Enabled reports whether a signing secret is configured
+func (w *webhookSender) Enabled() bool {
+	return len(w.secret) > 0
+}
+
+// SignWebhook returns the signature header value for a payload body: "sha256=" followed by
+// the hex HMAC-SHA256 of the body keyed with the webhook secret
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send POSTs the payload to url until it is accepted with a 2xx status, a permanent
+// error is returned, the attempts are used up or ctx is done
+func (w *webhookSender) Send(ctx context.Context, url string, payload WebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	signature := SignWebhook(w.secret, body)
+	logger := slog.With("request_id", payload.RequestID, "job_id", payload.JobID, "callback_url", url)
+
+	delay := w.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(ctx, url, payload, body, signature)
+		if err == nil {
+			logger.Info("webhook delivered", "attempt", attempt)
+			return nil
+		}
+		if !retry || attempt >= w.maxAttempts {
+			logger.Error("webhook delivery failed", "attempt", attempt, "error", err.Error())
+			return err
+		}
+
+		logger.Warn("webhook delivery failed, retrying", "attempt", attempt, "retry_in", delay.String(), "error", err.Error())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxWebhookBackoff)
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth retrying
+func (w *webhookSender) post(ctx context.Context, url string, payload WebhookPayload, body []byte, signature string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, payload.Event)
+	req.Header.Set(webhookDeliveryHeader, payload.JobID)
+	req.Header.Set(webhookSignatureHeader, signature)
+	req.Header.Set(requestIDHeader, payload.RequestID)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, errPrivateCallback), err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("callback returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("callback rejected with %s", resp.Status)
+	}
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/mabixdev/GoTypstMdToPDF/pkg/mdpdf"
+)
+
+// WorkerCommand is the hidden first argument that starts the binary as a compile worker
+const WorkerCommand = "__compile-worker"
+
+// workerLimits are the resource limits a compile worker applies to itself and the compiler it runs
+type workerLimits struct {
+	MemoryBytes int64         `json:"memoryBytes"` // data segment limit, 0 = unlimited
+	CPUTime     time.Duration `json:"cpuTime"`     // CPU time per compile, 0 = unlimited
+}
+
+// workerRequest is sent by the server to a compile worker, one JSON document per
+// job. The server creates the job root and removes it after the job, even when
+// it kills the worker.
+type workerRequest struct {
+	Root         string `json:"root"`
+	TypstContent string `json:"typstContent"`
+}
+
+// workerResponse is the worker's answer to a workerRequest
+type workerResponse struct {
+	PDF      []byte             `json:"pdf,omitempty"`
+	Warnings []mdpdf.Diagnostic `json:"warnings,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// RunCompileWorker serves compile requests from stdin until it is closed. It is
+// the entry point of the hidden worker subcommand; limits are read from the
+// environment set by the server.
+func RunCompileWorker(stdin io.Reader, stdout io.Writer) error {
+	var limits workerLimits
+	if err := json.Unmarshal([]byte(os.Getenv("MDPDF_WORKER_LIMITS")), &limits); err != nil {
+		return fmt.Errorf("invalid worker limits: %w", err)
+	}
+	// Limits are inherited by the Typst process started for each compile
+	if err := applyWorkerLimits(limits); err != nil {
+		return fmt.Errorf("failed to apply resource limits: %w", err)
+	}
+
+	decoder := json.NewDecoder(stdin)
+	encoder := json.NewEncoder(stdout)
+	for {
+		var req workerRequest
+		if err := decoder.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("invalid worker request: %w", err)
+		}
+
+		var resp workerResponse
+		// The server kills the worker when the job is cancelled
+		root := &sandboxRoot{dir: req.Root}
+		res, err := root.Compile(context.Background(), req.TypstContent)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.PDF, resp.Warnings = res.PDF, res.Warnings
+		}
+
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// workerProcess is a running compile worker
+type workerProcess struct {
+	cmd     *exec.Cmd
+	encoder *json.Encoder
+	decoder *json.Decoder
+	stdin   io.Closer
+	jobs    int
+}
+
+// processCompiler compiles in worker processes spawned from the service's own
+// binary. A worker is killed when its job times out and replaced after maxJobs jobs.
+type processCompiler struct {
+	executable  string
+	tempDir     string
+	templateDir string
+	limits      workerLimits
+	maxJobs     int
+
+	mu   sync.Mutex
+	idle []*workerProcess
+}
+
+func newProcessCompiler(config *Config) (*processCompiler, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot locate executable for compile workers: %w", err)
+	}
+
+	return &processCompiler{
+		executable:  executable,
+		tempDir:     config.TempDir,
+		templateDir: config.TemplateDir,
+		limits:      workerLimits{MemoryBytes: config.WorkerMemoryLimit, CPUTime: config.WorkerCPULimit},
+		maxJobs:     config.WorkerMaxJobs,
+	}, nil
+}
+
+// Compile runs one job on an idle or new worker in a job root it removes
+// afterwards. When ctx is done the worker and its compiler are killed.
+func (pc *processCompiler) Compile(ctx context.Context, typstContent string, assets map[string][]byte) (*mdpdf.ConvertResult, error) {
+	root, err := newSandboxRoot(pc.tempDir, pc.templateDir, assets)
+	if err != nil {
+		return nil, err
+	}
+	// Runs after a killed worker has been waited for, so nothing writes to the root any more
+	defer root.Remove()
+
+	w, err := pc.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		resp workerResponse
+		err  error
+	}
+	resultChan := make(chan result, 1)
+	go func() {
+		var res result
+		res.err = w.encoder.Encode(workerRequest{Root: root.dir, TypstContent: typstContent})
+		if res.err == nil {
+			res.err = w.decoder.Decode(&res.resp)
+		}
+		resultChan <- res
+	}()
+
+	select {
+	case <-ctx.Done():
+		slog.Warn("killing compile worker", "pid", w.cmd.Process.Pid, "error", ctx.Err().Error())
+		pc.discard(w)
+		return nil, ctx.Err()
+	case res := <-resultChan:
+		if res.err != nil {
+			pc.discard(w)
+			return nil, fmt.Errorf("compile worker failed: %w", res.err)
+		}
+		w.jobs++
+		pc.release(w)
+		if res.resp.Error != "" {
+			return nil, errors.New(res.resp.Error)
+		}
+		return &mdpdf.ConvertResult{PDF: res.resp.PDF, Warnings: res.resp.Warnings}, nil
+	}
+}
+
+// acquire returns an idle worker or starts a new one
+func (pc *processCompiler) acquire() (*workerProcess, error) {
+	pc.mu.Lock()
+	if n := len(pc.idle); n > 0 {
+		w := pc.idle[n-1]
+		pc.idle = pc.idle[:n-1]
+		pc.mu.Unlock()
+		return w, nil
+	}
+	pc.mu.Unlock()
+
+	return pc.start()
+}
+
+// start spawns a worker process
+func (pc *processCompiler) start() (*workerProcess, error) {
+	limits, err := json.Marshal(pc.limits)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(pc.executable, WorkerCommand)
+	cmd.Env = append(os.Environ(), "MDPDF_WORKER_LIMITS="+string(limits))
+	cmd.Stderr = os.Stderr
+	configureWorkerCmd(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start compile worker: %w", err)
+	}
+
+	slog.Debug("compile worker started", "pid", cmd.Process.Pid)
+	return &workerProcess{
+		cmd:     cmd,
+		encoder: json.NewEncoder(stdin),
+		decoder: json.NewDecoder(stdout),
+		stdin:   stdin,
+	}, nil
+}
+
+// release returns a healthy worker to the idle list, or retires it after maxJobs jobs
+func (pc *processCompiler) release(w *workerProcess) {
+	if pc.maxJobs > 0 && w.jobs >= pc.maxJobs {
+		slog.Debug("recycling compile worker", "pid", w.cmd.Process.Pid, "jobs", w.jobs)
+		w.stop()
+		return
+	}
+
+	pc.mu.Lock()
+	pc.idle = append(pc.idle, w)
+	pc.mu.Unlock()
+}
+
+// discard kills a worker that timed out or broke the protocol
+func (pc *processCompiler) discard(w *workerProcess) {
+	killWorker(w.cmd)
+	w.cmd.Wait()
+}
+
+// stop asks a worker to exit by closing its input
+func (w *workerProcess) stop() {
+	w.stdin.Close()
+	go w.cmd.Wait()
+}
+
+// Close stops all idle workers
+func (pc *processCompiler) Close() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	for _, w := range pc.idle {
+		w.stop()
+	}
+	pc.idle = nil
+}
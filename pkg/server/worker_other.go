@@ -0,0 +1,25 @@
+//go:build !(linux || darwin)
+
+package server
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// applyWorkerLimits is not supported on this platform
+func applyWorkerLimits(limits workerLimits) error {
+	if limits.MemoryBytes > 0 || limits.CPUTime > 0 {
+		return fmt.Errorf("worker resource limits are not supported on this platform")
+	}
+	return nil
+}
+
+func configureWorkerCmd(cmd *exec.Cmd) {}
+
+// killWorker kills the worker process
+func killWorker(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary act as a compile worker, as the service binary does
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == WorkerCommand {
+		if err := RunCompileWorker(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "compile worker:", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestProcessCompiler creates a process compiler that runs the test binary as its worker
+func newTestProcessCompiler(t *testing.T, limits workerLimits, maxJobs int) *processCompiler {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc := &processCompiler{executable: executable, tempDir: t.TempDir(), limits: limits, maxJobs: maxJobs}
+	t.Cleanup(pc.Close)
+	return pc
+}
+
+func TestProcessCompilerRecyclesWorkers(t *testing.T) {
+	pc := newTestProcessCompiler(t, workerLimits{MemoryBytes: 2 << 30, CPUTime: time.Minute}, 3)
+
+	expectedIdle := []int{1, 1, 0}
+	for i, want := range expectedIdle {
+		res, err := pc.Compile(context.Background(), fmt.Sprintf("= Document %d", i), nil)
+		if err != nil {
+			t.Fatalf("Compile %d failed: %v", i, err)
+		}
+		if !bytes.HasPrefix(res.PDF, []byte("%PDF")) {
+			t.Fatalf("Compile %d did not return a PDF", i)
+		}
+		if got := len(pc.idle); got != want {
+			t.Fatalf("After job %d expected %d idle workers, got %d", i+1, want, got)
+		}
+	}
+
+	// Compile errors are reported without losing the worker
+	if _, err := pc.Compile(context.Background(), "#undefined-function()", nil); err == nil {
+		t.Fatal("Expected compile error")
+	}
+	if len(pc.idle) != 1 {
+		t.Fatal("Worker was discarded after a compile error")
+	}
+}
+
+func TestProcessCompilerKillsOnTimeout(t *testing.T) {
+	pc := newTestProcessCompiler(t, workerLimits{}, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := pc.Compile(ctx, "#for i in range(1000000000) { }", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Compile was not interrupted (took %v)", elapsed)
+	}
+	if len(pc.idle) != 0 {
+		t.Fatal("Killed worker was returned to the idle list")
+	}
+
+	// The job root is removed although the worker never finished the job
+	entries, err := os.ReadDir(pc.tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Timed out job left %s in the temp directory", entries[0].Name())
+	}
+}
+
+func TestProcessCompilerMemoryLimit(t *testing.T) {
+	pc := newTestProcessCompiler(t, workerLimits{MemoryBytes: 512 << 20}, 10)
+
+	// Roughly 4GB of array elements
+	_, err := pc.Compile(context.Background(), "#let big = range(250000000).map(i => i)", nil)
+	if err == nil {
+		t.Fatal("Expected the memory limit to stop the compile")
+	}
+
+	// The worker survives and serves the next job
+	if _, err := pc.Compile(context.Background(), "= Still alive", nil); err != nil {
+		t.Fatalf("Compile after memory limit failed: %v", err)
+	}
+}
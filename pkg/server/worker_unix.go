@@ -0,0 +1,43 @@
+//go:build linux || darwin
+
+package server
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// applyWorkerLimits sets the data segment and CPU time rlimits of the current process.
+// RLIMIT_DATA is used rather than RLIMIT_AS because the Go runtime reserves far more
+// address space than it uses.
+func applyWorkerLimits(limits workerLimits) error {
+	if limits.MemoryBytes > 0 {
+		limit := uint64(limits.MemoryBytes)
+		if err := syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return err
+		}
+	}
+	if limits.CPUTime > 0 {
+		seconds := uint64((limits.CPUTime + 999999999) / 1e9) // round up to whole seconds
+		if err := syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: seconds, Max: seconds}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configureWorkerCmd starts the worker in its own process group so that
+// killWorker also reaches the Typst process it runs
+func configureWorkerCmd(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killWorker kills the worker's process group
+func killWorker(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -42,7 +42,7 @@ async function checkServiceHealth() {
         if (response.ok && data.status === 'healthy') {
             updateHealthStatus('healthy', '✅ Service is healthy');
         } else {
-            updateHealthStatus('unhealthy', `❌ Service unavailable: ${data.error || 'Unknown error'}`);
+            updateHealthStatus('unhealthy', `❌ Service unavailable: ${data.message || data.error || 'Unknown error'}`);
         }
     } catch (error) {
         updateHealthStatus('unhealthy', `❌ Connection failed: ${error.message}`);
@@ -89,7 +89,8 @@ async function convertMarkdownToPDF(markdownContent, options = {}) {
     showStatusMessage('info', 'Starting PDF generation from Markdown...');
     
     try {
-        const response = await fetch('/api/convert-to-pdf', {
+        // Submit an async job and follow its progress instead of blocking on the download
+        const response = await fetch('/api/jobs', {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
@@ -101,12 +102,19 @@ async function convertMarkdownToPDF(markdownContent, options = {}) {
         });
         
         if (!response.ok) {
-            const errorData = await response.json();
-            throw new Error(errorData.error || `HTTP ${response.status}: ${response.statusText}`);
+            throw await responseError(response);
         }
         
-        // Handle PDF download
-        const blob = await response.blob();
+        const job = await response.json();
+        await followJobProgress(job.id);
+        
+        // Download the finished PDF
+        const pdfResponse = await fetch(`/api/jobs/${job.id}/pdf`);
+        if (!pdfResponse.ok) {
+            throw await responseError(pdfResponse);
+        }
+        
+        const blob = await pdfResponse.blob();
         
         if (blob.size === 0) {
             throw new Error('Received empty PDF file');
@@ -121,10 +129,77 @@ async function convertMarkdownToPDF(markdownContent, options = {}) {
     } catch (error) {
         throw error;
     } finally {
+        clearProgress();
         setConvertingState(false);
     }
 }
 
+// Build an Error from a failed API response
+async function responseError(response) {
+    let errorData = {};
+    try {
+        errorData = await response.json();
+    } catch (e) {
+        // Not a JSON error body
+    }
+    const message = errorData.jobError || errorData.error || `HTTP ${response.status}: ${response.statusText}`;
+    return new Error(errorData.requestId ? `${message} (request ${errorData.requestId})` : message);
+}
+
+// Follow the Server-Sent Events of a job until its result is ready
+function followJobProgress(jobId) {
+    return new Promise((resolve, reject) => {
+        const source = new EventSource(`/api/jobs/${jobId}/events`);
+        const finish = (callback, value) => {
+            source.close();
+            callback(value);
+        };
+        const on = (name, handler) => source.addEventListener(name, (event) => handler(JSON.parse(event.data)));
+        
+        on('queued', (data) => {
+            showProgress(data.position ? `⏳ Waiting for a free compiler (position ${data.position} in queue)...` : '⏳ Queued...');
+        });
+        on('template_loaded', (data) => {
+            showProgress(`📄 Template "${data.template}" loaded...`);
+        });
+        on('compile_started', () => {
+            showProgress('⚙️ Compiling with Typst...');
+        });
+        on('compile_finished', (data) => {
+            showProgress(data.cached ? '⚡ Served from cache...' : `✔️ Compiled in ${data.durationMs} ms, preparing download...`);
+        });
+        on('result_ready', (data) => finish(resolve, data));
+        on('failed', (data) => finish(reject, new Error(data.error)));
+        
+        source.onerror = () => {
+            // EventSource reconnects on its own while the connection is recoverable
+            if (source.readyState === EventSource.CLOSED) {
+                finish(reject, new Error('Lost connection to the progress stream'));
+            }
+        };
+    });
+}
+
+// Show or update the single in-progress status message
+function showProgress(message) {
+    let progressDiv = document.getElementById('conversion-progress');
+    if (!progressDiv) {
+        progressDiv = document.createElement('div');
+        progressDiv.id = 'conversion-progress';
+        progressDiv.className = 'status-message status-info';
+        statusMessages.appendChild(progressDiv);
+    }
+    progressDiv.textContent = message;
+}
+
+// Remove the in-progress status message
+function clearProgress() {
+    const progressDiv = document.getElementById('conversion-progress');
+    if (progressDiv) {
+        progressDiv.remove();
+    }
+}
+
 // Download blob as file
 function downloadBlob(blob, filename) {
     const url = window.URL.createObjectURL(blob);